@@ -0,0 +1,83 @@
+package flash
+
+import (
+	"sync"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// WithJSONArray configures the logger to write the entries into the file of WithFile as a
+// single JSON array instead of JSON lines, for example for the log of a batch job. The
+// opening bracket is written with the first entry and the closing bracket by Close. If
+// the logger is not closed, for example after a crash, the array is not terminated.
+//
+// An existing file is truncated and the file is not rotated. NewE returns an error, if no
+// file is configured with WithFile, if the file configures MaxSize, if the file is already
+// used by another logger or if another encoder than JSON is configured after this option.
+func WithJSONArray() Option {
+	return func(c *config) {
+		c.encoder = JSON
+		c.jsonArray = true
+	}
+}
+
+// jsonArraySink is a zapcore.WriteSyncer writing the entries as elements of a JSON array.
+type jsonArraySink struct {
+	zapcore.WriteSyncer
+	m       sync.Mutex
+	written bool
+	closed  bool
+}
+
+// newJSONArraySink wraps sink. The returned function terminates the array and closes the
+// sink with closeSink.
+func newJSONArraySink(sink zapcore.WriteSyncer, closeSink func()) (zapcore.WriteSyncer, func()) {
+	s := &jsonArraySink{WriteSyncer: sink}
+
+	return s, func() {
+		s.close()
+		closeSink()
+	}
+}
+
+func (s *jsonArraySink) Write(p []byte) (int, error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	sep := byte(',')
+	if !s.written {
+		sep = '['
+	}
+
+	buf := make([]byte, 0, len(p)+1)
+	buf = append(buf, sep)
+	buf = append(buf, p...)
+
+	if _, err := s.WriteSyncer.Write(buf); err != nil {
+		return 0, err
+	}
+
+	s.written = true
+
+	return len(p), nil
+}
+
+// close writes the closing bracket, or an empty array if no entries were written.
+func (s *jsonArraySink) close() {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if s.closed {
+		return
+	}
+
+	s.closed = true
+
+	end := "]\n"
+	if !s.written {
+		end = "[]\n"
+	}
+
+	_, _ = s.WriteSyncer.Write([]byte(end))
+	_ = s.WriteSyncer.Sync()
+}
@@ -0,0 +1,77 @@
+package flash
+
+import (
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Audit returns a logger for audit events. It logs to the same sinks with the same
+// encoder, but has its own level, which is `InfoLevel` and does not change with the level
+// of l. All audit loggers of l share their level. Closing the audit logger does not close
+// the sinks, they are closed with l.
+func (l *Logger) Audit() *Logger {
+	base := l.unfiltered.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return &levelCore{Core: core, level: l.auditAtom}
+	}))
+
+	logger := base
+	if !l.disableStackTrace {
+		logger = base.WithOptions(zap.AddStacktrace(zap.FatalLevel))
+	}
+
+	return &Logger{
+		SugaredLogger:     logger.Sugar(),
+		skipped:           skipCaller(logger.Sugar()),
+		base:              base,
+		sinks:             l.sinks,
+		teeFiles:          l.teeFiles,
+		journaldSocket:    l.journaldSocket,
+		disableCaller:     l.disableCaller,
+		atom:              l.auditAtom,
+		currentLevel:      l.auditAtom.Level(),
+		disableStackTrace: l.disableStackTrace,
+		unfiltered:        l.unfiltered,
+		auditAtom:         l.auditAtom,
+		contextFields:     l.contextFields,
+		requestIDKey:      l.requestIDKey,
+		events:            l.events,
+	}
+}
+
+// anyLevel is a zapcore.LevelEnabler enabling the levels enabled by any of its levels.
+type anyLevel []zapcore.LevelEnabler
+
+func (a anyLevel) Enabled(level zapcore.Level) bool {
+	for _, l := range a {
+		if l.Enabled(level) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// levelCore is a zapcore.Core wrapper which only logs entries enabled by level.
+type levelCore struct {
+	zapcore.Core
+	level zapcore.LevelEnabler
+}
+
+func (c *levelCore) Enabled(level zapcore.Level) bool {
+	return c.level.Enabled(level)
+}
+
+func (c *levelCore) With(fields []zapcore.Field) zapcore.Core {
+	return &levelCore{
+		Core:  c.Core.With(fields),
+		level: c.level,
+	}
+}
+
+func (c *levelCore) Check(e zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.level.Enabled(e.Level) {
+		return ce
+	}
+
+	return c.Core.Check(e, ce)
+}
@@ -0,0 +1,227 @@
+package flash
+
+import (
+	"path"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// LogAtCaller logs a message at level with the caller of the given program counter,
+// for example captured with runtime.Caller, instead of the caller of LogAtCaller.
+func (l *Logger) LogAtCaller(pc uintptr, level zapcore.Level, msg string, fields ...zap.Field) {
+	ce := l.Desugar().WithOptions(zap.WithCaller(false)).Check(level, msg)
+	if ce == nil {
+		return
+	}
+
+	if !l.disableCaller {
+		frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+		ce.Caller = zapcore.EntryCaller{
+			Defined:  frame.PC != 0,
+			PC:       frame.PC,
+			File:     frame.File,
+			Line:     frame.Line,
+			Function: frame.Function,
+		}
+	}
+
+	ce.Write(fields...)
+}
+
+// LogAt logs a message at level with the timestamp t instead of the current time, for
+// example to replay historical events.
+func (l *Logger) LogAt(t time.Time, level zapcore.Level, msg string, fields ...zap.Field) {
+	ce := l.callerSkipped().Desugar().Check(level, msg)
+	if ce == nil {
+		return
+	}
+
+	ce.Time = t
+	ce.Write(fields...)
+}
+
+// moduleRelativePath returns a function formatting the caller path relative to root. If
+// the caller is not within root, the short path is returned.
+func moduleRelativePath(root string) func(zapcore.EntryCaller) string {
+	file := callerFile(root)
+
+	return func(c zapcore.EntryCaller) string {
+		if !c.Defined {
+			return c.TrimmedPath()
+		}
+
+		return file(c) + ":" + strconv.Itoa(c.Line)
+	}
+}
+
+// callerFile returns a function returning the file of the caller relative to root. If
+// root is empty or the caller is not within root, the short `package/file.go` is returned.
+func callerFile(root string) func(zapcore.EntryCaller) string {
+	prefix := strings.TrimSuffix(filepath.ToSlash(root), "/") + "/"
+
+	return func(c zapcore.EntryCaller) string {
+		if root == "" || !strings.HasPrefix(c.File, prefix) {
+			return strings.TrimSuffix(c.TrimmedPath(), ":"+strconv.Itoa(c.Line))
+		}
+
+		return strings.TrimPrefix(c.File, prefix)
+	}
+}
+
+// WithSplitCaller annotates logs of the JSON encoder with the caller as separate `file`
+// and `line` fields instead of a combined `caller`. It has no effect on other encoders.
+func WithSplitCaller() Option {
+	return func(c *config) {
+		c.splitCaller = true
+	}
+}
+
+// splitCaller is a transformer which adds the caller of entries as file and line fields
+// and removes it from the entry.
+type splitCaller func(zapcore.EntryCaller) string
+
+func (t splitCaller) transform(e zapcore.Entry, dst, fields []zapcore.Field) (zapcore.Entry, []zapcore.Field) {
+	dst = append(dst, fields...)
+
+	if !e.Caller.Defined {
+		return e, dst
+	}
+
+	dst = append(dst, zap.String("file", t(e.Caller)), zap.Int("line", e.Caller.Line))
+	e.Caller = zapcore.EntryCaller{}
+
+	return e, dst
+}
+
+// callerLevel is a transformer which removes the caller from all entries below the level.
+type callerLevel zapcore.Level
+
+func (t callerLevel) transform(e zapcore.Entry, dst, fields []zapcore.Field) (zapcore.Entry, []zapcore.Field) {
+	if e.Level < zapcore.Level(t) {
+		e.Caller = zapcore.EntryCaller{}
+	}
+
+	return e, append(dst, fields...)
+}
+
+// WithPackageField annotates logs with the import path of the caller's package in a field
+// with key. It has no effect if the caller is disabled with WithoutCaller.
+func WithPackageField(key string) Option {
+	return func(c *config) {
+		c.packageKey = key
+	}
+}
+
+// packageField is a transformer which adds the package of the caller of entries in a
+// field with the key.
+type packageField string
+
+func (t packageField) transform(e zapcore.Entry, dst, fields []zapcore.Field) (zapcore.Entry, []zapcore.Field) {
+	if pkg := callerPackage(e.Caller); pkg != "" {
+		dst = append(dst, zap.String(string(t), pkg))
+	}
+
+	return e, append(dst, fields...)
+}
+
+// callerPackage returns the import path of the package of the caller function, for
+// example `github.com/postfinance/flash` for `github.com/postfinance/flash.(*Logger).Info`.
+func callerPackage(c zapcore.EntryCaller) string {
+	fn := c.Function
+	if fn == "" && c.PC != 0 {
+		if f := runtime.FuncForPC(c.PC); f != nil {
+			fn = f.Name()
+		}
+	}
+
+	slash := strings.LastIndex(fn, "/")
+	if dot := strings.Index(fn[slash+1:], "."); dot >= 0 {
+		return fn[:slash+1+dot]
+	}
+
+	return fn
+}
+
+// nolint: gochecknoglobals
+var defaultWrapperPackages = []string{"logging", "logger"}
+
+// WithWrapperDetection annotates logs with the first caller outside of logging wrappers,
+// instead of the caller of the logger, which is often a helper function of a logging
+// package. A function is a wrapper, if the name of its package, the last element of the
+// import path, contains one of the substrings configured with WithWrapperPackages, by
+// default `logging` and `logger`.
+func WithWrapperDetection() Option {
+	return func(c *config) {
+		if c.wrapperPackages == nil {
+			c.wrapperPackages = defaultWrapperPackages
+		}
+	}
+}
+
+// WithWrapperPackages configures the package name substrings of logging wrappers skipped
+// by WithWrapperDetection. Short substrings match unrelated packages, for example `log`
+// matches `catalog` and `login`. It implies WithWrapperDetection.
+func WithWrapperPackages(substrings ...string) Option {
+	return func(c *config) {
+		c.wrapperPackages = substrings
+	}
+}
+
+// wrapperCaller is a transformer which replaces callers in the wrapper packages with the
+// first caller outside of them.
+type wrapperCaller []string
+
+func (t wrapperCaller) transform(e zapcore.Entry, dst, fields []zapcore.Field) (zapcore.Entry, []zapcore.Field) {
+	if e.Caller.Defined && t.isWrapper(callerPackage(e.Caller)) {
+		e.Caller = t.caller(e.Caller)
+	}
+
+	return e, append(dst, fields...)
+}
+
+// caller walks the stack up from caller and returns the first caller outside of the
+// wrapper packages. If there is none, caller is returned.
+func (t wrapperCaller) caller(caller zapcore.EntryCaller) zapcore.EntryCaller {
+	pcs := make([]uintptr, 64)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+	found := false
+
+	for {
+		frame, more := frames.Next()
+
+		switch {
+		case !found:
+			found = frame.PC == caller.PC
+		case !t.isWrapper(callerPackage(zapcore.EntryCaller{Function: frame.Function})):
+			return zapcore.EntryCaller{
+				Defined:  true,
+				PC:       frame.PC,
+				File:     frame.File,
+				Line:     frame.Line,
+				Function: frame.Function,
+			}
+		}
+
+		if !more {
+			return caller
+		}
+	}
+}
+
+func (t wrapperCaller) isWrapper(pkg string) bool {
+	name := path.Base(pkg)
+
+	for _, s := range t {
+		if strings.Contains(name, s) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,216 @@
+package flash_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/postfinance/flash"
+	"github.com/postfinance/flash/testdata/logging"
+	"github.com/stretchr/testify/require"
+	"github.com/tj/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestLogAtCaller(t *testing.T) {
+	defer sink.Reset()
+
+	pc, _, line := capturePC()
+
+	l := flash.New(flash.WithSinks("memory://"))
+	l.LogAtCaller(pc, zapcore.WarnLevel, "message", zap.String("key", "value"))
+
+	e, err := sink.parse()
+	require.NoError(t, err)
+	require.Len(t, e, 1)
+	assert.Equal(t, "WARN", e[0].Level)
+	assert.Equal(t, fmt.Sprintf("flash/caller_test.go:%d", line), e[0].Caller)
+
+	t.Run("without caller", func(t *testing.T) {
+		sink.Reset()
+
+		l := flash.New(flash.WithSinks("memory://"), flash.WithoutCaller())
+		l.LogAtCaller(pc, zapcore.InfoLevel, "message")
+
+		e, err := sink.parse()
+		require.NoError(t, err)
+		assert.Empty(t, e[0].Caller)
+	})
+}
+
+func capturePC() (uintptr, string, int) {
+	pc, file, line, _ := runtime.Caller(0)
+	return pc, file, line
+}
+
+func TestWithCallerFromLevel(t *testing.T) {
+	defer sink.Reset()
+
+	l := flash.New(flash.WithSinks("memory://"), flash.WithCallerFromLevel(zapcore.WarnLevel))
+	l.Info("info")
+	l.Warn("warn")
+	l.Error("error")
+
+	e, err := sink.parse()
+	require.NoError(t, err)
+	require.Len(t, e, 3)
+	assert.Empty(t, e[0].Caller)
+	assert.NotEmpty(t, e[1].Caller)
+	assert.NotEmpty(t, e[2].Caller)
+
+	t.Run("WithoutCaller takes precedence", func(t *testing.T) {
+		sink.Reset()
+
+		l := flash.New(flash.WithSinks("memory://"), flash.WithCallerFromLevel(zapcore.WarnLevel), flash.WithoutCaller())
+		l.Error("error")
+
+		e, err := sink.parse()
+		require.NoError(t, err)
+		assert.Empty(t, e[0].Caller)
+	})
+}
+
+func TestWithCallerOnError(t *testing.T) {
+	defer sink.Reset()
+
+	l := flash.New(flash.WithSinks("memory://"), flash.WithEncoder(flash.JSON), flash.WithCallerOnError())
+	l.Info("info")
+	l.Warn("warn")
+	l.Error("error")
+
+	lines := strings.Split(strings.TrimSpace(sink.String()), "\n")
+	require.Len(t, lines, 3)
+	assert.NotContains(t, lines[0], `"caller"`)
+	assert.NotContains(t, lines[1], `"caller"`)
+	assert.Contains(t, lines[2], `"caller":"flash/caller_test.go`)
+}
+
+func TestLogAt(t *testing.T) {
+	defer sink.Reset()
+
+	ts := time.Date(2019, time.March, 1, 12, 30, 15, 0, time.UTC)
+
+	l := flash.New(flash.WithSinks("memory://"))
+	l.LogAt(ts, zapcore.InfoLevel, "replayed", zap.String("key", "value"))
+	_, _, line, _ := runtime.Caller(0)
+
+	out := sink.String()
+
+	e, err := sink.parse()
+	require.NoError(t, err)
+	require.Len(t, e, 1)
+	assert.Equal(t, fmt.Sprintf("flash/caller_test.go:%d", line-1), e[0].Caller)
+	assert.Contains(t, out, `"ts":"2019-03-01T12:30:15.000Z"`)
+}
+
+func TestWithCallerModuleRelative(t *testing.T) {
+	defer sink.Reset()
+
+	_, file, _, _ := runtime.Caller(0)
+
+	l := flash.New(flash.WithSinks("memory://"), flash.WithCallerModuleRelative(filepath.Dir(file)))
+	l.Info("relative")
+	_, _, line, _ := runtime.Caller(0)
+
+	e, err := sink.parse()
+	require.NoError(t, err)
+	require.Len(t, e, 1)
+	assert.Equal(t, fmt.Sprintf("caller_test.go:%d", line-1), e[0].Caller)
+
+	t.Run("outside of root", func(t *testing.T) {
+		sink.Reset()
+
+		l := flash.New(flash.WithSinks("memory://"), flash.WithCallerModuleRelative("/does/not/exist"))
+		l.Info("short")
+		_, _, line, _ := runtime.Caller(0)
+
+		e, err := sink.parse()
+		require.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("flash/caller_test.go:%d", line-1), e[0].Caller)
+	})
+}
+
+func TestWithSplitCaller(t *testing.T) {
+	defer sink.Reset()
+
+	l := flash.New(flash.WithSinks("memory://"), flash.WithEncoder(flash.JSON), flash.WithSplitCaller())
+	l.Info("split")
+	_, _, line, _ := runtime.Caller(0)
+
+	var entry map[string]interface{}
+
+	require.NoError(t, json.Unmarshal([]byte(sink.String()), &entry))
+	assert.Equal(t, "flash/caller_test.go", entry["file"])
+	assert.Equal(t, float64(line-1), entry["line"])
+	assert.NotContains(t, entry, "caller")
+
+	t.Run("console", func(t *testing.T) {
+		sink.Reset()
+
+		l := flash.New(flash.WithSinks("memory://"), flash.WithEncoder(flash.Console), flash.WithSplitCaller())
+		l.Info("combined")
+		_, _, line, _ := runtime.Caller(0)
+
+		assert.Contains(t, sink.String(), fmt.Sprintf("flash/caller_test.go:%d", line-1))
+		assert.NotContains(t, sink.String(), `"line"`)
+	})
+}
+
+func TestWithPackageField(t *testing.T) {
+	defer sink.Reset()
+
+	l := flash.New(flash.WithSinks("memory://"), flash.WithEncoder(flash.JSON), flash.WithPackageField("pkg"))
+	l.Info("package")
+
+	var entry map[string]interface{}
+
+	require.NoError(t, json.Unmarshal([]byte(sink.String()), &entry))
+	assert.Equal(t, "github.com/postfinance/flash_test", entry["pkg"])
+
+	t.Run("without caller", func(t *testing.T) {
+		sink.Reset()
+
+		l := flash.New(flash.WithSinks("memory://"), flash.WithEncoder(flash.JSON), flash.WithPackageField("pkg"), flash.WithoutCaller())
+		l.Info("package")
+
+		var entry map[string]interface{}
+
+		require.NoError(t, json.Unmarshal([]byte(sink.String()), &entry))
+		assert.NotContains(t, entry, "pkg")
+	})
+}
+
+func TestWithWrapperDetection(t *testing.T) {
+	defer sink.Reset()
+
+	l := flash.New(flash.WithSinks("memory://"), flash.WithWrapperDetection())
+	logging.Info(l, "wrapped")
+	_, _, line, _ := runtime.Caller(0)
+
+	e, err := sink.parse()
+	require.NoError(t, err)
+	require.Len(t, e, 1)
+	assert.Equal(t, fmt.Sprintf("flash/caller_test.go:%d", line-1), e[0].Caller)
+
+	t.Run("packages", func(t *testing.T) {
+		sink.Reset()
+
+		l := flash.New(flash.WithSinks("memory://"), flash.WithWrapperPackages("logg"))
+		logging.Info(l, "wrapped")
+		_, _, line, _ := runtime.Caller(0)
+
+		l = flash.New(flash.WithSinks("memory://"), flash.WithWrapperPackages("testdata"))
+		logging.Info(l, "not wrapped")
+
+		e, err := sink.parse()
+		require.NoError(t, err)
+		require.Len(t, e, 2)
+		assert.Equal(t, fmt.Sprintf("flash/caller_test.go:%d", line-1), e[0].Caller)
+		assert.Equal(t, "logging/logging.go:12", e[1].Caller)
+	})
+}
@@ -0,0 +1,50 @@
+package flash
+
+import (
+	"fmt"
+	"time"
+)
+
+// Close flushes all buffered log entries and closes the sinks of the logger. The
+// logger must not be used after Close.
+func (l *Logger) Close() error {
+	err := l.Sync()
+
+	l.closeOnce.Do(func() {
+		if l.closeSinks != nil {
+			l.closeSinks()
+		}
+	})
+
+	return err
+}
+
+// FatalFlush syncs all sinks, including buffered and asynchronous ones, and then logs
+// args at fatal level like Fatal, which exits the process. Fatal itself syncs the sinks
+// only after writing the fatal entry.
+func (l *Logger) FatalFlush(args ...interface{}) {
+	_ = l.Sync()
+
+	l.callerSkipped().Fatal(args...)
+}
+
+// CloseWithTimeout is like Close, but returns an error, if flushing and closing takes
+// longer than d. This prevents a hanging sink from blocking the shutdown of a process.
+//
+// Close cannot be canceled: on timeout, the goroutine running Close is left behind and
+// keeps blocking until the sink returns. It is meant for shutting down a process, where
+// the goroutine ends with the process.
+func (l *Logger) CloseWithTimeout(d time.Duration) error {
+	errc := make(chan error, 1)
+
+	go func() {
+		errc <- l.Close()
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-time.After(d):
+		return fmt.Errorf("could not close logger within %s", d)
+	}
+}
@@ -0,0 +1,53 @@
+package flash
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/tj/assert"
+)
+
+// recordingSyncer is a zapcore.WriteSyncer recording its writes and syncs.
+type recordingSyncer struct {
+	m      sync.Mutex
+	events []string
+}
+
+func (r *recordingSyncer) Write(p []byte) (int, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	r.events = append(r.events, "write "+strings.TrimSpace(string(p)))
+
+	return len(p), nil
+}
+
+func (r *recordingSyncer) Sync() error {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	r.events = append(r.events, "sync")
+
+	return nil
+}
+
+func TestFatalFlush(t *testing.T) {
+	out := &recordingSyncer{}
+
+	l := New(WithNoFatalExit(), WithEncoder(LogFmt), WithoutTimestamps(), WithoutCaller(), func(c *config) {
+		c.output = out
+	})
+
+	out.events = nil
+
+	l.Info("before")
+	l.FatalFlush("fatal")
+
+	assert.Equal(t, []string{
+		"write level=INFO msg=before",
+		"sync",
+		"write level=FATAL msg=fatal",
+		"sync",
+	}, out.events)
+}
@@ -0,0 +1,74 @@
+package flash
+
+import (
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	alignedLevelWidth  = 5
+	alignedCallerWidth = 30
+)
+
+// levelColors are the ANSI color codes used by zapcore.CapitalColorLevelEncoder.
+// nolint: gochecknoglobals
+var levelColors = map[zapcore.Level]int{
+	zapcore.DebugLevel:  35, // magenta
+	zapcore.InfoLevel:   34, // blue
+	zapcore.WarnLevel:   33, // yellow
+	zapcore.ErrorLevel:  31, // red
+	zapcore.DPanicLevel: 31,
+	zapcore.PanicLevel:  31,
+	zapcore.FatalLevel:  31,
+}
+
+// colorize wraps s in the ANSI color of the level.
+func colorize(level zapcore.Level, s string) string {
+	c, ok := levelColors[level]
+	if !ok {
+		c = 31
+	}
+
+	return fmt.Sprintf("\x1b[%dm%s\x1b[0m", c, s)
+}
+
+// customColorize returns a function, which wraps s in the escape sequence returned by
+// color for the level. If the sequence is empty, s is returned unchanged.
+func customColorize(color func(zapcore.Level) string) func(zapcore.Level, string) string {
+	return func(level zapcore.Level, s string) string {
+		seq := color(level)
+		if seq == "" {
+			return s
+		}
+
+		return seq + s + "\x1b[0m"
+	}
+}
+
+// padRight pads s with spaces to width.
+func padRight(s string, width int) string {
+	if len(s) >= width {
+		return s
+	}
+
+	return s + strings.Repeat(" ", width-len(s))
+}
+
+// paddedLevelEncoder returns a level encoder, which pads the capitalized level
+// to width and then colors it with colored, so that the color codes do not count
+// towards the width.
+func paddedLevelEncoder(width int, colored func(zapcore.Level, string) string) zapcore.LevelEncoder {
+	return func(l zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
+		enc.AppendString(colored(l, padRight(l.CapitalString(), width)))
+	}
+}
+
+// paddedCallerEncoder returns a caller encoder, which pads the caller formatted by path
+// to width.
+func paddedCallerEncoder(width int, path func(zapcore.EntryCaller) string) zapcore.CallerEncoder {
+	return func(c zapcore.EntryCaller, enc zapcore.PrimitiveArrayEncoder) {
+		enc.AppendString(padRight(path(c), width))
+	}
+}
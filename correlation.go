@@ -0,0 +1,121 @@
+package flash
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// CorrelationIDHeader is the HTTP header carrying the correlation ID of a request.
+	CorrelationIDHeader = "X-Correlation-ID"
+	// RequestIDHeader is an alternative HTTP header for the correlation ID of a request.
+	RequestIDHeader = "X-Request-ID"
+
+	correlationIDKey = "correlation_id"
+
+	maxCorrelationIDLength = 128
+)
+
+type contextKey struct{}
+
+type requestIDContextKey struct{}
+
+// nolint: gochecknoglobals
+var correlationIDCounter uint64
+
+// NewCorrelationID returns a new random correlation ID of 32 hexadecimal characters. If
+// the random number generator fails, the ID is built from the current time and a counter,
+// so that it is still unique within the process.
+func NewCorrelationID() string {
+	b := make([]byte, 16)
+
+	if _, err := rand.Read(b); err != nil {
+		binary.BigEndian.PutUint64(b, uint64(time.Now().UnixNano()))
+		binary.BigEndian.PutUint64(b[8:], atomic.AddUint64(&correlationIDCounter, 1))
+	}
+
+	return hex.EncodeToString(b)
+}
+
+// CorrelationID returns the first valid correlation ID of the CorrelationIDHeader and the
+// RequestIDHeader keys returned by get, for example the header of an HTTP request, or a
+// new one created with NewCorrelationID. Valid IDs have at most 128 characters of letters,
+// digits, `.`, `_` and `-`, so that clients cannot inject arbitrary content into logs and
+// response headers.
+func CorrelationID(get func(key string) string) string {
+	for _, key := range []string{CorrelationIDHeader, RequestIDHeader} {
+		if id := get(key); validCorrelationID(id) {
+			return id
+		}
+	}
+
+	return NewCorrelationID()
+}
+
+// NewCorrelationContext returns a copy of ctx storing a request-scoped logger with a
+// `correlation_id` field with id. The logger has the fields added by Ctx. Handlers get
+// the logger with FromContext. The middleware of the flashhttp package and the
+// interceptors of the flashgrpc module use it for each request.
+func (l *Logger) NewCorrelationContext(ctx context.Context, id string) context.Context {
+	return NewContext(ctx, l.Ctx(ctx).With(correlationIDKey, id))
+}
+
+// validCorrelationID reports whether id is a non-empty correlation ID of at most 128
+// characters of letters, digits, `.`, `_` and `-`.
+func validCorrelationID(id string) bool {
+	if id == "" || len(id) > maxCorrelationIDLength {
+		return false
+	}
+
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '_', r == '-':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// WithRequestIDKey configures Ctx to add the request ID stored in the context with
+// NewRequestIDContext as field with key.
+func WithRequestIDKey(key string) Option {
+	return func(c *config) {
+		c.requestIDKey = key
+	}
+}
+
+// NewRequestIDContext returns a copy of ctx storing the request ID id, for example the
+// AwsRequestID of the lambdacontext in AWS Lambda handlers.
+func NewRequestIDContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey{}, id)
+}
+
+// RequestIDFromContext returns the request ID stored in ctx with NewRequestIDContext.
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(requestIDContextKey{}).(string)
+	return id, ok
+}
+
+// NewContext returns a copy of ctx storing logger.
+func NewContext(ctx context.Context, logger *zap.SugaredLogger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext returns the logger stored in ctx with NewContext or NewCorrelationContext.
+// If ctx does not contain a logger, the global sugared logger of zap.S is returned, which
+// discards all entries, unless a logger is created WithGlobal.
+func FromContext(ctx context.Context) *zap.SugaredLogger {
+	if logger, ok := ctx.Value(contextKey{}).(*zap.SugaredLogger); ok {
+		return logger
+	}
+
+	return zap.S()
+}
@@ -0,0 +1,55 @@
+package flash_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/postfinance/flash"
+	"github.com/postfinance/flash/flashtest"
+	"github.com/tj/assert"
+)
+
+func TestCorrelationID(t *testing.T) {
+	t.Run("incoming header", func(t *testing.T) {
+		for _, header := range []string{flash.CorrelationIDHeader, flash.RequestIDHeader} {
+			h := http.Header{}
+			h.Set(header, "incoming-"+header)
+
+			assert.Equal(t, "incoming-"+header, flash.CorrelationID(h.Get))
+		}
+	})
+
+	t.Run("invalid header", func(t *testing.T) {
+		for _, id := range []string{"injected\nlevel=error", "id with spaces", strings.Repeat("a", 129)} {
+			h := http.Header{}
+			h.Set(flash.CorrelationIDHeader, id)
+			h.Set(flash.RequestIDHeader, "valid-id_1.0")
+
+			assert.Equal(t, "valid-id_1.0", flash.CorrelationID(h.Get))
+
+			h.Del(flash.RequestIDHeader)
+
+			generated := flash.CorrelationID(h.Get)
+			assert.Len(t, generated, 32)
+			assert.NotEqual(t, id, generated)
+		}
+	})
+
+	t.Run("generated", func(t *testing.T) {
+		id := flash.CorrelationID(http.Header{}.Get)
+		assert.Len(t, id, 32)
+		assert.NotEqual(t, id, flash.NewCorrelationID())
+	})
+}
+
+func TestNewCorrelationContext(t *testing.T) {
+	l, entries := flashtest.CaptureT(t)
+
+	ctx := l.NewCorrelationContext(context.Background(), "id")
+	flash.FromContext(ctx).Info("handled")
+
+	e := entries()
+	assert.Equal(t, "id", e[len(e)-1].Fields["correlation_id"])
+}
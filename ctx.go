@@ -0,0 +1,33 @@
+package flash
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+// WithContextFields configures a function returning fields of a context as key-value
+// pairs like With, which are added by Ctx. The flashotel module uses it to add members of
+// the OpenTelemetry baggage.
+func WithContextFields(fn func(ctx context.Context) []interface{}) Option {
+	return func(c *config) {
+		c.contextFields = append(c.contextFields, fn)
+	}
+}
+
+// Ctx returns the logger with the fields of ctx returned by the functions configured
+// WithContextFields. If configured WithRequestIDKey, the request ID stored in ctx with
+// NewRequestIDContext is added too.
+func (l *Logger) Ctx(ctx context.Context) *zap.SugaredLogger {
+	var fields []interface{}
+
+	for _, fn := range l.contextFields {
+		fields = append(fields, fn(ctx)...)
+	}
+
+	if id, ok := RequestIDFromContext(ctx); ok && l.requestIDKey != "" {
+		fields = append(fields, l.requestIDKey, id)
+	}
+
+	return l.Get().With(fields...)
+}
@@ -0,0 +1,38 @@
+package flash_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/postfinance/flash"
+	"github.com/stretchr/testify/require"
+	"github.com/tj/assert"
+)
+
+type tenantKey struct{}
+
+func TestWithContextFields(t *testing.T) {
+	defer sink.Reset()
+
+	l := flash.New(flash.WithSinks("memory://"), flash.WithContextFields(func(ctx context.Context) []interface{} {
+		if tenant, ok := ctx.Value(tenantKey{}).(string); ok {
+			return []interface{}{"tenant", tenant}
+		}
+
+		return nil
+	}))
+	l.Ctx(context.WithValue(context.Background(), tenantKey{}, "acme")).Info("with tenant")
+
+	assert.Contains(t, sink.String(), `"tenant":"acme"`)
+
+	t.Run("without fields", func(t *testing.T) {
+		sink.Reset()
+
+		l.Ctx(context.Background()).Info("without tenant")
+
+		e, err := sink.parse()
+		require.NoError(t, err)
+		require.Len(t, e, 1)
+		assert.NotContains(t, sink.String(), "tenant")
+	})
+}
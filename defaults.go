@@ -0,0 +1,86 @@
+package flash
+
+import (
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// WithDefaults adds default fields to every entry, which are dropped, if a field with the
+// same key is added by With or the logging call. The args are key-value pairs or fields
+// like for the `w` methods of zap.SugaredLogger. Keys which are not strings are ignored.
+func WithDefaults(args ...interface{}) Option {
+	return func(c *config) {
+		c.defaults = append(c.defaults, sweetenFields(args)...)
+	}
+}
+
+// sweetenFields converts key-value pairs and fields to fields.
+func sweetenFields(args []interface{}) []zapcore.Field {
+	fields := make([]zapcore.Field, 0, len(args)/2)
+
+	for i := 0; i < len(args); i++ {
+		if f, ok := args[i].(zapcore.Field); ok {
+			fields = append(fields, f)
+			continue
+		}
+
+		if i == len(args)-1 {
+			break
+		}
+
+		if key, ok := args[i].(string); ok {
+			fields = append(fields, zap.Any(key, args[i+1]))
+		}
+
+		i++
+	}
+
+	return fields
+}
+
+// defaultFields is a transformer which adds the default fields, which are not overridden.
+type defaultFields []zapcore.Field
+
+// with removes the defaults overridden by fields.
+func (t defaultFields) with(fields []zapcore.Field) ([]zapcore.Field, transformer) {
+	return fields, defaultFields(withoutKeys(t, fields))
+}
+
+func (t defaultFields) transform(e zapcore.Entry, dst, fields []zapcore.Field) (zapcore.Entry, []zapcore.Field) {
+	dst = append(dst, withoutKeys(t, fields)...)
+
+	return e, append(dst, fields...)
+}
+
+// withoutKeys returns the defaults without a key of fields. The defaults are only copied,
+// if one is removed.
+func withoutKeys(defaults, fields []zapcore.Field) []zapcore.Field {
+	overridden := func(key string) bool {
+		for i := range fields {
+			if fields[i].Key == key {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	for i := range defaults {
+		if !overridden(defaults[i].Key) {
+			continue
+		}
+
+		remaining := make([]zapcore.Field, 0, len(defaults)-1)
+		remaining = append(remaining, defaults[:i]...)
+
+		for _, d := range defaults[i+1:] {
+			if !overridden(d.Key) {
+				remaining = append(remaining, d)
+			}
+		}
+
+		return remaining
+	}
+
+	return defaults
+}
@@ -0,0 +1,283 @@
+package flash
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// truncated replaces values nested deeper than the maximum depth.
+const truncated = "…"
+
+// WithMaxEncodeDepth limits the nesting of fields added with zap.Any or zap.Reflect to n
+// levels of maps, slices and structs. Deeper values are replaced by `…`, so that deeply
+// nested or self-referential values cannot produce huge entries.
+//
+// Values within the limit are encoded unchanged. Of values exceeding the limit, the maps,
+// slices and structs containing too deep values are copied into maps and slices before
+// encoding. The copies of structs contain their exported fields like encoding/json, but
+// fields of unexported embedded structs are omitted.
+func WithMaxEncodeDepth(n int) Option {
+	return func(c *config) {
+		c.maxEncodeDepth = n
+	}
+}
+
+// depthLimitedEncoder returns a reflected encoder factory, which limits the depth of the
+// values before encoding them with the encoders created by newEncoder. If newEncoder is
+// nil, encoding/json is used like by zap.
+func depthLimitedEncoder(n int, newEncoder func(io.Writer) zapcore.ReflectedEncoder) func(io.Writer) zapcore.ReflectedEncoder {
+	if newEncoder == nil {
+		newEncoder = func(w io.Writer) zapcore.ReflectedEncoder {
+			enc := json.NewEncoder(w)
+			enc.SetEscapeHTML(false)
+
+			return enc
+		}
+	}
+
+	return func(w io.Writer) zapcore.ReflectedEncoder {
+		return &depthEncoder{
+			enc: newEncoder(w),
+			max: n,
+		}
+	}
+}
+
+type depthEncoder struct {
+	enc zapcore.ReflectedEncoder
+	max int
+}
+
+func (e *depthEncoder) Encode(v interface{}) error {
+	return e.enc.Encode(e.limit(reflect.ValueOf(v), 0))
+}
+
+// nolint: gochecknoglobals
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// isMarshaler reports whether v is encoded by its own MarshalJSON or MarshalText method,
+// like by encoding/json.
+func isMarshaler(v reflect.Value) bool {
+	t := v.Type()
+	if t.Implements(jsonMarshalerType) || t.Implements(textMarshalerType) {
+		return true
+	}
+
+	pt := reflect.PtrTo(t)
+
+	return v.CanAddr() && (pt.Implements(jsonMarshalerType) || pt.Implements(textMarshalerType))
+}
+
+// container returns v without pointers and interfaces and whether it is a non-nil map,
+// slice, array or struct, which is traversed to limit the depth.
+func container(v reflect.Value) (reflect.Value, bool) {
+	for v.IsValid() && !isMarshaler(v) {
+		switch v.Kind() { // nolint: exhaustive
+		case reflect.Ptr, reflect.Interface:
+			if v.IsNil() {
+				return v, false
+			}
+
+			v = v.Elem()
+		case reflect.Map, reflect.Slice:
+			return v, !v.IsNil() && !(v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8)
+		case reflect.Array, reflect.Struct:
+			return v, true
+		default:
+			return v, false
+		}
+	}
+
+	return v, false
+}
+
+// exceeds reports whether v at depth contains maps, slices or structs nested deeper than
+// max.
+func (e *depthEncoder) exceeds(v reflect.Value, depth int) bool {
+	v, ok := container(v)
+	if !ok {
+		return false
+	}
+
+	if depth >= e.max {
+		return true
+	}
+
+	switch v.Kind() { // nolint: exhaustive
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			if e.exceeds(iter.Value(), depth+1) {
+				return true
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if e.exceeds(v.Index(i), depth+1) {
+				return true
+			}
+		}
+	default:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).PkgPath == "" && e.exceeds(v.Field(i), depth+1) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// limit returns v, if it does not exceed the maximum depth. Otherwise it returns a copy
+// of v with all maps, slices and structs nested deeper than max replaced. Values within
+// the limit are not copied.
+func (e *depthEncoder) limit(v reflect.Value, depth int) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+
+	if !e.exceeds(v, depth) {
+		// keep methods with pointer receivers, which encoding/json calls on
+		// addressable values
+		if v.Kind() != reflect.Ptr && v.CanAddr() && isMarshaler(v) {
+			return v.Addr().Interface()
+		}
+
+		return v.Interface()
+	}
+
+	v, _ = container(v)
+
+	if depth >= e.max {
+		return truncated
+	}
+
+	switch v.Kind() { // nolint: exhaustive
+	case reflect.Map:
+		m := make(map[string]interface{}, v.Len())
+
+		iter := v.MapRange()
+		for iter.Next() {
+			m[fmt.Sprint(iter.Key().Interface())] = e.limit(iter.Value(), depth+1)
+		}
+
+		return m
+	case reflect.Slice, reflect.Array:
+		s := make([]interface{}, v.Len())
+		for i := range s {
+			s[i] = e.limit(v.Index(i), depth+1)
+		}
+
+		return s
+	default:
+		m := make(map[string]interface{}, v.NumField())
+		e.limitStruct(m, v, depth)
+
+		return m
+	}
+}
+
+// limitStruct adds the exported fields of the struct v to m with the keys used by
+// encoding/json. The fields of embedded structs without a name tag are promoted, unless
+// m already contains a field with the same key.
+func (e *depthEncoder) limitStruct(m map[string]interface{}, v reflect.Value, depth int) {
+	t := v.Type()
+
+	var embedded []reflect.Value
+
+	for i := 0; i < v.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+
+		name, opts := f.Name, ""
+
+		if tag, ok := f.Tag.Lookup("json"); ok {
+			if tag == "-" {
+				continue
+			}
+
+			if comma := strings.Index(tag, ","); comma >= 0 {
+				tag, opts = tag[:comma], tag[comma:]
+			}
+
+			if tag != "" {
+				name = tag
+			}
+		}
+
+		fv := v.Field(i)
+
+		if f.Anonymous && name == f.Name {
+			if sv, ok := container(fv); ok && sv.Kind() == reflect.Struct {
+				embedded = append(embedded, sv)
+				continue
+			}
+		}
+
+		if strings.Contains(opts, ",omitempty") && isEmptyJSONValue(fv) {
+			continue
+		}
+
+		if strings.Contains(opts, ",string") {
+			if s, ok := quoted(fv); ok {
+				m[name] = s
+				continue
+			}
+		}
+
+		m[name] = e.limit(fv, depth+1)
+	}
+
+	for _, sv := range embedded {
+		promoted := make(map[string]interface{}, sv.NumField())
+		e.limitStruct(promoted, sv, depth)
+
+		for k, pv := range promoted {
+			if _, ok := m[k]; !ok {
+				m[k] = pv
+			}
+		}
+	}
+}
+
+// isEmptyJSONValue reports whether v is empty like for the omitempty option of
+// encoding/json.
+func isEmptyJSONValue(v reflect.Value) bool {
+	switch v.Kind() { // nolint: exhaustive
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Interface, reflect.Ptr:
+		return v.IsZero()
+	}
+
+	return false
+}
+
+// quoted returns the value of a field with the string option of encoding/json, which
+// applies to strings, numbers and booleans.
+func quoted(v reflect.Value) (string, bool) {
+	switch v.Kind() { // nolint: exhaustive
+	case reflect.String:
+		b, err := json.Marshal(v.String())
+		return string(b), err == nil
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return fmt.Sprint(v.Interface()), true
+	}
+
+	return "", false
+}
@@ -0,0 +1,79 @@
+package flash
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// ParseFileConfig parses a file configuration from a DSN like
+//
+//	file:///var/log/app.log?maxsize=100&maxbackups=3&maxage=7&compress=true
+//
+// The supported query parameters are the lowercase names of the FileConfig fields:
+// `maxsize`, `maxbackups`, `maxage`, `compress`, `compressexisting` and `synceachline`.
+func ParseFileConfig(dsn string) (FileConfig, error) {
+	var fc FileConfig
+
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return fc, fmt.Errorf("invalid file dsn %q: %w", dsn, err)
+	}
+
+	if u.Scheme != "" && u.Scheme != "file" {
+		return fc, fmt.Errorf("invalid file dsn %q: unsupported scheme %q", dsn, u.Scheme)
+	}
+
+	switch {
+	case u.Opaque != "":
+		fc.Path = u.Opaque
+	default:
+		fc.Path = u.Host + u.Path
+	}
+
+	if fc.Path == "" {
+		return fc, fmt.Errorf("invalid file dsn %q: path must not be empty", dsn)
+	}
+
+	for key, values := range u.Query() {
+		value := values[len(values)-1]
+
+		switch strings.ToLower(key) {
+		case "maxsize":
+			fc.MaxSize, err = strconv.Atoi(value)
+		case "maxbackups":
+			fc.MaxBackups, err = strconv.Atoi(value)
+		case "maxage":
+			fc.MaxAge, err = strconv.Atoi(value)
+		case "compress":
+			fc.Compress, err = strconv.ParseBool(value)
+		case "compressexisting":
+			fc.CompressExisting, err = strconv.ParseBool(value)
+		case "synceachline":
+			fc.SyncEachLine, err = strconv.ParseBool(value)
+		default:
+			return fc, fmt.Errorf("invalid file dsn %q: unknown parameter %q", dsn, key)
+		}
+
+		if err != nil {
+			return fc, fmt.Errorf("invalid file dsn %q: invalid value %q for %s", dsn, value, key)
+		}
+	}
+
+	return fc, nil
+}
+
+// WithFileDSN configures the logger to log output into the file configured by dsn, as
+// parsed by ParseFileConfig. If dsn is invalid, NewE returns an error.
+func WithFileDSN(dsn string) Option {
+	return func(c *config) {
+		fc, err := ParseFileConfig(dsn)
+		if err != nil {
+			c.fileErr = err
+			return
+		}
+
+		c.fileConfig = &fc
+	}
+}
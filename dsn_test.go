@@ -0,0 +1,76 @@
+package flash_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/postfinance/flash"
+	"github.com/stretchr/testify/require"
+	"github.com/tj/assert"
+)
+
+func TestParseFileConfig(t *testing.T) {
+	tt := []struct {
+		dsn     string
+		want    flash.FileConfig
+		wantErr string
+	}{
+		{
+			dsn: "file:///var/log/app.log?maxsize=100&maxbackups=3&maxage=7&compress=true&compressexisting=1&synceachline=false",
+			want: flash.FileConfig{
+				Path:             "/var/log/app.log",
+				MaxSize:          100,
+				MaxBackups:       3,
+				MaxAge:           7,
+				Compress:         true,
+				CompressExisting: true,
+			},
+		},
+		{dsn: "/var/log/app.log", want: flash.FileConfig{Path: "/var/log/app.log"}},
+		{dsn: "file:app.log?maxage=1", want: flash.FileConfig{Path: "app.log", MaxAge: 1}},
+		{dsn: "file:///var/log/app.log?maxsize=large", wantErr: `invalid value "large" for maxsize`},
+		{dsn: "file:///var/log/app.log?compress=maybe", wantErr: `invalid value "maybe" for compress`},
+		{dsn: "file:///var/log/app.log?size=1", wantErr: `unknown parameter "size"`},
+		{dsn: "http://localhost/app.log", wantErr: `unsupported scheme "http"`},
+		{dsn: "file://?maxsize=1", wantErr: "path must not be empty"},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.dsn, func(t *testing.T) {
+			fc, err := flash.ParseFileConfig(tc.dsn)
+			if tc.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.wantErr)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, fc)
+		})
+	}
+}
+
+func TestWithFileDSN(t *testing.T) {
+	dir, err := ioutil.TempDir("", "flash")
+	require.NoError(t, err)
+
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	l, err := flash.NewE(flash.WithFileDSN("file://" + dir + "/dsn.log?maxsize=1"))
+	require.NoError(t, err)
+
+	l.Info("hello world")
+	l.Close()
+
+	d, err := ioutil.ReadFile(dir + "/dsn.log")
+	require.NoError(t, err)
+	assert.Contains(t, string(d), "hello world")
+
+	_, err = flash.NewE(flash.WithFileDSN("file:///var/log/app.log?maxage=week"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,74 @@
+package flash
+
+import (
+	"errors"
+	"sort"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// fielder is implemented by errors with structured context.
+type fielder interface {
+	Fields() map[string]interface{}
+}
+
+// WithErrorFields adds the fields of logged errors, which implement
+//
+//	interface{ Fields() map[string]interface{} }
+//
+// to the entry, with their keys prefixed by prefix. Wrapped errors are unwrapped to find
+// the fields.
+func WithErrorFields(prefix string) Option {
+	return func(c *config) {
+		c.errorFieldsPrefix = &prefix
+	}
+}
+
+// errorFields is a transformer which adds the fields of errors implementing fielder with
+// the keys prefixed by the prefix.
+type errorFields string
+
+func (t errorFields) with(fields []zapcore.Field) ([]zapcore.Field, transformer) {
+	return t.expand(make([]zapcore.Field, 0, len(fields)), fields), t
+}
+
+func (t errorFields) transform(e zapcore.Entry, dst, fields []zapcore.Field) (zapcore.Entry, []zapcore.Field) {
+	return e, t.expand(dst, fields)
+}
+
+// expand appends fields to dst, each error field followed by the fields of the error.
+func (t errorFields) expand(dst, fields []zapcore.Field) []zapcore.Field {
+	for i := range fields {
+		dst = append(dst, fields[i])
+
+		if fields[i].Type != zapcore.ErrorType {
+			continue
+		}
+
+		err, ok := fields[i].Interface.(error)
+		if !ok {
+			continue
+		}
+
+		var f fielder
+		if !errors.As(err, &f) {
+			continue
+		}
+
+		values := f.Fields()
+
+		keys := make([]string, 0, len(values))
+		for k := range values {
+			keys = append(keys, k)
+		}
+
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			dst = append(dst, zap.Any(string(t)+k, values[k]))
+		}
+	}
+
+	return dst
+}
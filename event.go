@@ -0,0 +1,78 @@
+package flash
+
+import (
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	eventCodeKey   = "event_code"
+	otherEventCode = "other"
+)
+
+// Event logs msg at level with a stable machine readable code in an `event_code` field,
+// for example for alerting on important entries.
+func (l *Logger) Event(code string, level zapcore.Level, msg string, fields ...zap.Field) {
+	ce := l.callerSkipped().Desugar().Check(level, msg)
+	if ce == nil {
+		return
+	}
+
+	if l.events != nil {
+		l.events.count(code, level)
+	}
+
+	ce.Write(append(fields[:len(fields):len(fields)], zap.String(eventCodeKey, code))...)
+}
+
+// WithPrometheusEventCodes registers a prometheus counter of the entries logged with
+// Event, partitioned by log level and event code:
+//
+//	<appName>_log_events_total
+//
+// To bound the number of time series, only the given codes are used as label values, all
+// other codes are counted as `other`. If appName is an empty string `flash` is used.
+func WithPrometheusEventCodes(appName string, registry prometheus.Registerer, codes ...string) Option {
+	return func(c *config) {
+		name := appName
+		if name == "" {
+			name = "flash"
+		}
+
+		counter := prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: fmt.Sprintf("%s_log_events_total", name),
+				Help: "How many events logged, partitioned by log level and event code.",
+			},
+			[]string{"level", "code"},
+		)
+		registry.MustRegister(counter)
+
+		known := make(map[string]struct{}, len(codes))
+		for _, code := range codes {
+			known[code] = struct{}{}
+		}
+
+		c.events = &eventCounter{
+			counter: counter,
+			codes:   known,
+		}
+	}
+}
+
+// eventCounter counts events with a bounded set of code labels.
+type eventCounter struct {
+	counter *prometheus.CounterVec
+	codes   map[string]struct{}
+}
+
+func (e *eventCounter) count(code string, level zapcore.Level) {
+	if _, ok := e.codes[code]; !ok {
+		code = otherEventCode
+	}
+
+	e.counter.WithLabelValues(level.String(), code).Inc()
+}
@@ -0,0 +1,92 @@
+package flash
+
+import (
+	"strings"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// eventID is the id of all events. Event ids of sources installed with EventCreate.exe
+// must be between 1 and 1000.
+const eventID = 1
+
+// WithEventLog additionally writes all entries to the Windows Event Log with the given
+// source. Entries at `ErrorLevel` and above are written as errors, `WarnLevel` as warnings and
+// all others as information events. On other systems, NewE returns an error.
+func WithEventLog(source string) Option {
+	return func(c *config) {
+		c.eventLogSource = source
+	}
+}
+
+// eventWriter writes events, it is implemented by *eventlog.Log.
+type eventWriter interface {
+	Info(eid uint32, msg string) error
+	Warning(eid uint32, msg string) error
+	Error(eid uint32, msg string) error
+}
+
+// eventLogCore is a zapcore.Core writing entries as events. The event type is given by
+// the level, so the level is not encoded.
+type eventLogCore struct {
+	zapcore.LevelEnabler
+	enc zapcore.Encoder
+	w   eventWriter
+}
+
+func newEventLogCore(w eventWriter, encoderConfig zapcore.EncoderConfig, level zapcore.LevelEnabler) zapcore.Core {
+	// the event log records the time and the type of events
+	encoderConfig.TimeKey = ""
+	encoderConfig.LevelKey = ""
+
+	return &eventLogCore{
+		LevelEnabler: level,
+		enc:          zapcore.NewJSONEncoder(encoderConfig),
+		w:            w,
+	}
+}
+
+func (c *eventLogCore) With(fields []zapcore.Field) zapcore.Core {
+	enc := c.enc.Clone()
+	for i := range fields {
+		fields[i].AddTo(enc)
+	}
+
+	return &eventLogCore{
+		LevelEnabler: c.LevelEnabler,
+		enc:          enc,
+		w:            c.w,
+	}
+}
+
+func (c *eventLogCore) Check(e zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(e.Level) {
+		return ce.AddCore(e, c)
+	}
+
+	return ce
+}
+
+func (c *eventLogCore) Write(e zapcore.Entry, fields []zapcore.Field) error {
+	buf, err := c.enc.EncodeEntry(e, fields)
+	if err != nil {
+		return err
+	}
+
+	msg := strings.TrimSuffix(buf.String(), "\n")
+	buf.Free()
+
+	switch {
+	case e.Level >= zapcore.ErrorLevel:
+		return c.w.Error(eventID, msg)
+	case e.Level == zapcore.WarnLevel:
+		return c.w.Warning(eventID, msg)
+	default:
+		return c.w.Info(eventID, msg)
+	}
+}
+
+// Sync implements zapcore.Core. Events are not buffered.
+func (c *eventLogCore) Sync() error {
+	return nil
+}
@@ -0,0 +1,15 @@
+//go:build !windows
+// +build !windows
+
+package flash
+
+import (
+	"errors"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// openEventLog returns an error, the event log is only available on Windows.
+func openEventLog(string, zapcore.EncoderConfig, zapcore.LevelEnabler) (zapcore.Core, func(), error) {
+	return nil, nil, errors.New("the event log is only supported on windows")
+}
@@ -0,0 +1,44 @@
+package flash
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tj/assert"
+	"go.uber.org/zap"
+)
+
+// fakeEventLog records the written events by type.
+type fakeEventLog struct {
+	events map[string][]string
+}
+
+func (f *fakeEventLog) Info(_ uint32, msg string) error {
+	f.events["info"] = append(f.events["info"], msg)
+	return nil
+}
+
+func (f *fakeEventLog) Warning(_ uint32, msg string) error {
+	f.events["warning"] = append(f.events["warning"], msg)
+	return nil
+}
+
+func (f *fakeEventLog) Error(_ uint32, msg string) error {
+	f.events["error"] = append(f.events["error"], msg)
+	return nil
+}
+
+func TestEventLogCore(t *testing.T) {
+	w := &fakeEventLog{events: map[string][]string{}}
+
+	l := zap.New(newEventLogCore(w, zap.NewProductionEncoderConfig(), zap.DebugLevel)).With(zap.String("app", "flash"))
+	l.Debug("debug")
+	l.Info("info", zap.Int("n", 1))
+	l.Warn("warn")
+	l.Error("error")
+
+	require.Len(t, w.events["info"], 2)
+	require.Len(t, w.events["warning"], 1)
+	require.Len(t, w.events["error"], 1)
+	assert.Equal(t, `{"msg":"info","app":"flash","n":1}`, w.events["info"][1])
+}
@@ -0,0 +1,22 @@
+//go:build windows
+// +build windows
+
+package flash
+
+import (
+	"fmt"
+
+	"go.uber.org/zap/zapcore"
+	"golang.org/x/sys/windows/svc/eventlog"
+)
+
+// openEventLog creates a core writing to the event log of source. It returns a function
+// to close the event log.
+func openEventLog(source string, encoderConfig zapcore.EncoderConfig, level zapcore.LevelEnabler) (zapcore.Core, func(), error) {
+	l, err := eventlog.Open(source)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not open event log %s: %w", source, err)
+	}
+
+	return newEventLogCore(l, encoderConfig, level), func() { _ = l.Close() }, nil
+}
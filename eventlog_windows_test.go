@@ -0,0 +1,82 @@
+//go:build windows
+// +build windows
+
+package flash
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tj/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"golang.org/x/sys/windows/svc/eventlog"
+)
+
+// recordingEventLog writes the events to the event log and records them by type like
+// fakeEventLog.
+type recordingEventLog struct {
+	eventWriter
+	fakeEventLog
+}
+
+func (r *recordingEventLog) Info(eid uint32, msg string) error {
+	_ = r.fakeEventLog.Info(eid, msg)
+	return r.eventWriter.Info(eid, msg)
+}
+
+func (r *recordingEventLog) Warning(eid uint32, msg string) error {
+	_ = r.fakeEventLog.Warning(eid, msg)
+	return r.eventWriter.Warning(eid, msg)
+}
+
+func (r *recordingEventLog) Error(eid uint32, msg string) error {
+	_ = r.fakeEventLog.Error(eid, msg)
+	return r.eventWriter.Error(eid, msg)
+}
+
+func TestWithEventLog(t *testing.T) {
+	const source = "flash-test"
+
+	if err := eventlog.InstallAsEventCreate(source, eventlog.Error|eventlog.Warning|eventlog.Info); err != nil {
+		t.Skipf("could not install event source: %v", err)
+	}
+
+	defer func() {
+		_ = eventlog.Remove(source)
+	}()
+
+	core, closeEventLog, err := openEventLog(source, zap.NewProductionEncoderConfig(), zap.InfoLevel)
+	require.NoError(t, err)
+
+	defer closeEventLog()
+
+	c, ok := core.(*eventLogCore)
+	require.True(t, ok)
+
+	w := &recordingEventLog{
+		eventWriter:  c.w,
+		fakeEventLog: fakeEventLog{events: map[string][]string{}},
+	}
+	c.w = w
+
+	for _, e := range []zapcore.Entry{
+		{Level: zap.ErrorLevel, Message: "error event"},
+		{Level: zap.WarnLevel, Message: "warning event"},
+		{Level: zap.InfoLevel, Message: "information event"},
+	} {
+		require.NoError(t, c.Write(e, nil))
+	}
+
+	assert.Equal(t, map[string][]string{
+		"error":   {`{"msg":"error event"}`},
+		"warning": {`{"msg":"warning event"}`},
+		"info":    {`{"msg":"information event"}`},
+	}, w.events)
+
+	t.Run("option", func(t *testing.T) {
+		l, err := NewE(WithSinks("stderr"), WithEventLog(source))
+		require.NoError(t, err)
+		require.NoError(t, l.Close())
+	})
+}
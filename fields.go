@@ -0,0 +1,331 @@
+package flash
+
+import (
+	"encoding/hex"
+	"math"
+	"reflect"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Hex returns a field with b encoded as lowercase hexadecimal string, for example for
+// hashes. Binary fields added with zap.Binary are encoded as base64 by all encoders.
+func Hex(key string, b []byte) zap.Field {
+	return zap.String(key, hex.EncodeToString(b))
+}
+
+// nolint: gochecknoglobals
+var fieldsPool = sync.Pool{
+	New: func() interface{} {
+		fields := make([]zapcore.Field, 0, 16)
+		return &fields
+	},
+}
+
+// getFields returns an empty field slice from the pool.
+func getFields() *[]zapcore.Field {
+	p := fieldsPool.Get().(*[]zapcore.Field)
+	*p = (*p)[:0]
+
+	return p
+}
+
+// putFields resets the field slice and returns it to the pool. The fields
+// are zeroed so that the pool does not keep logged values alive.
+func putFields(p *[]zapcore.Field) {
+	fields := *p
+	for i := range fields {
+		fields[i] = zapcore.Field{}
+	}
+
+	*p = fields[:0]
+	fieldsPool.Put(p)
+}
+
+// transformer changes the entries and fields of a transformCore.
+type transformer interface {
+	// transform returns the entry to write and the fields to write appended to dst.
+	transform(e zapcore.Entry, dst, fields []zapcore.Field) (zapcore.Entry, []zapcore.Field)
+}
+
+// contextTransformer is a transformer, which also changes the context fields added with
+// With.
+type contextTransformer interface {
+	transformer
+	// with returns the context fields for the wrapped core and the transformer for the
+	// core with the context fields.
+	with(fields []zapcore.Field) ([]zapcore.Field, transformer)
+}
+
+// transformCore is a zapcore.Core wrapper which passes every entry through a transformer
+// before it is written by the wrapped core.
+//
+// The fields are transformed into a slice from fieldsPool, to avoid an allocation per
+// entry. The slice is returned to the pool and zeroed after the wrapped Write returns, so
+// it is only valid during the call of the wrapped Write. Wrapped cores retaining the fields,
+// for example to write them asynchronously, have to copy them.
+type transformCore struct {
+	zapcore.Core
+	t transformer
+}
+
+func (c *transformCore) With(fields []zapcore.Field) zapcore.Core {
+	t := c.t
+
+	if ct, ok := t.(contextTransformer); ok {
+		fields, t = ct.with(fields)
+	}
+
+	return &transformCore{
+		Core: c.Core.With(fields),
+		t:    t,
+	}
+}
+
+func (c *transformCore) Check(e zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(e.Level) {
+		return ce.AddCore(e, c)
+	}
+
+	return ce
+}
+
+func (c *transformCore) Write(e zapcore.Entry, fields []zapcore.Field) error {
+	p := getFields()
+	defer putFields(p)
+
+	e, *p = c.t.transform(e, *p, fields)
+
+	return c.Core.Write(e, *p)
+}
+
+// skipFields is a transformer which drops all fields with a configured key.
+type skipFields map[string]struct{}
+
+func newSkipFields(keys []string) skipFields {
+	m := make(skipFields, len(keys))
+	for _, k := range keys {
+		m[k] = struct{}{}
+	}
+
+	return m
+}
+
+func (t skipFields) with(fields []zapcore.Field) ([]zapcore.Field, transformer) {
+	return t.filter(make([]zapcore.Field, 0, len(fields)), fields), t
+}
+
+func (t skipFields) transform(e zapcore.Entry, dst, fields []zapcore.Field) (zapcore.Entry, []zapcore.Field) {
+	return e, t.filter(dst, fields)
+}
+
+// filter appends all fields not to be skipped to dst.
+func (t skipFields) filter(dst, fields []zapcore.Field) []zapcore.Field {
+	for i := range fields {
+		if _, ok := t[fields[i].Key]; ok {
+			continue
+		}
+
+		dst = append(dst, fields[i])
+	}
+
+	return dst
+}
+
+// mapFields is a transformer which replaces every field with the result of the function.
+// Fields for which the function returns false are dropped.
+type mapFields func(zapcore.Field) (zapcore.Field, bool)
+
+func (t mapFields) with(fields []zapcore.Field) ([]zapcore.Field, transformer) {
+	return t.apply(make([]zapcore.Field, 0, len(fields)), fields), t
+}
+
+func (t mapFields) transform(e zapcore.Entry, dst, fields []zapcore.Field) (zapcore.Entry, []zapcore.Field) {
+	return e, t.apply(dst, fields)
+}
+
+func (t mapFields) apply(dst, fields []zapcore.Field) []zapcore.Field {
+	for i := range fields {
+		if f, ok := t(fields[i]); ok {
+			dst = append(dst, f)
+		}
+	}
+
+	return dst
+}
+
+// nolint: gochecknoglobals
+var severityNumbers = map[zapcore.Level]int{
+	zapcore.DebugLevel:  5,
+	zapcore.InfoLevel:   9,
+	zapcore.WarnLevel:   13,
+	zapcore.ErrorLevel:  17,
+	zapcore.DPanicLevel: 18,
+	zapcore.PanicLevel:  19,
+	zapcore.FatalLevel:  21,
+}
+
+// severityField is a transformer which adds the OpenTelemetry severity number of the level
+// to every entry in a field with the key.
+type severityField string
+
+func (t severityField) transform(e zapcore.Entry, dst, fields []zapcore.Field) (zapcore.Entry, []zapcore.Field) {
+	dst = append(dst, zap.Int(string(t), severityNumbers[e.Level]))
+
+	return e, append(dst, fields...)
+}
+
+// allowKeys returns a field map function, which drops all fields without one of keys.
+func allowKeys(keys []string) func(zapcore.Field) (zapcore.Field, bool) {
+	m := make(map[string]struct{}, len(keys))
+	for _, k := range keys {
+		m[k] = struct{}{}
+	}
+
+	return func(f zapcore.Field) (zapcore.Field, bool) {
+		_, ok := m[f.Key]
+		return f, ok
+	}
+}
+
+// renameKeys returns a field map function, which renames the keys found in mapping.
+func renameKeys(mapping map[string]string) func(zapcore.Field) (zapcore.Field, bool) {
+	return func(f zapcore.Field) (zapcore.Field, bool) {
+		if key, ok := mapping[f.Key]; ok {
+			f.Key = key
+		}
+
+		return f, true
+	}
+}
+
+// renameErrorKey returns a field map function, which renames the key of error
+// fields added by zap.Error or by passing an error to a sugared logger.
+func renameErrorKey(key string) func(zapcore.Field) (zapcore.Field, bool) {
+	return func(f zapcore.Field) (zapcore.Field, bool) {
+		if f.Type == zapcore.ErrorType && f.Key == "error" {
+			f.Key = key
+		}
+
+		return f, true
+	}
+}
+
+// encodeTimeFields returns a field map function, which encodes the values of time
+// fields with enc. The encoded values replace the time values, so that the time
+// encoder of the entry timestamp does not apply to them.
+func encodeTimeFields(enc zapcore.TimeEncoder) func(zapcore.Field) (zapcore.Field, bool) {
+	return func(f zapcore.Field) (zapcore.Field, bool) {
+		var t time.Time
+
+		switch f.Type { // nolint: exhaustive
+		case zapcore.TimeType:
+			t = time.Unix(0, f.Integer)
+			if loc, ok := f.Interface.(*time.Location); ok && loc != nil {
+				t = t.In(loc)
+			}
+		case zapcore.TimeFullType:
+			t, _ = f.Interface.(time.Time)
+		default:
+			return f, true
+		}
+
+		m := zapcore.NewMapObjectEncoder()
+		_ = m.AddArray(f.Key, zapcore.ArrayMarshalerFunc(func(ae zapcore.ArrayEncoder) error {
+			enc(t, ae)
+			return nil
+		}))
+
+		values, _ := m.Fields[f.Key].([]interface{})
+		if len(values) != 1 {
+			return zap.Any(f.Key, values), true
+		}
+
+		return zap.Any(f.Key, values[0]), true
+	}
+}
+
+// transformFields returns a field map function, which applies the transforms
+// configured for the key of a field.
+func transformFields(transforms map[string][]func(zapcore.Field) zapcore.Field) func(zapcore.Field) (zapcore.Field, bool) {
+	return func(f zapcore.Field) (zapcore.Field, bool) {
+		for _, fn := range transforms[f.Key] {
+			f = fn(f)
+		}
+
+		return f, true
+	}
+}
+
+// omitEmpty returns a field map function, which drops fields with empty values: empty
+// strings and byte strings, nil values and empty slices, maps and arrays. If zero is true,
+// fields with zero numbers, false booleans, zero durations and zero times are dropped too.
+func omitEmpty(zero bool) func(zapcore.Field) (zapcore.Field, bool) {
+	return func(f zapcore.Field) (zapcore.Field, bool) {
+		return f, !isEmpty(f) && !(zero && isZero(f))
+	}
+}
+
+// isEmpty reports whether f has an empty value.
+func isEmpty(f zapcore.Field) bool {
+	switch f.Type { // nolint: exhaustive
+	case zapcore.StringType:
+		return f.String == ""
+	case zapcore.BinaryType, zapcore.ByteStringType:
+		b, _ := f.Interface.([]byte)
+		return len(b) == 0
+	case zapcore.ArrayMarshalerType, zapcore.ObjectMarshalerType, zapcore.ReflectType, zapcore.StringerType, zapcore.ErrorType:
+		return isEmptyValue(f.Interface)
+	default:
+		return false
+	}
+}
+
+// isEmptyValue reports whether v is nil, a nil pointer or an empty string, slice, map or
+// array.
+func isEmptyValue(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+
+	rv := reflect.ValueOf(v)
+
+	switch rv.Kind() { // nolint: exhaustive
+	case reflect.Ptr, reflect.Interface, reflect.Func, reflect.Chan:
+		return rv.IsNil()
+	case reflect.Slice, reflect.Map:
+		return rv.IsNil() || rv.Len() == 0
+	case reflect.Array, reflect.String:
+		return rv.Len() == 0
+	default:
+		return false
+	}
+}
+
+// isZero reports whether f has a zero number, false boolean, zero duration or zero time.
+func isZero(f zapcore.Field) bool {
+	switch f.Type { // nolint: exhaustive
+	case zapcore.BoolType, zapcore.DurationType,
+		zapcore.Int64Type, zapcore.Int32Type, zapcore.Int16Type, zapcore.Int8Type,
+		zapcore.Uint64Type, zapcore.Uint32Type, zapcore.Uint16Type, zapcore.Uint8Type, zapcore.UintptrType:
+		return f.Integer == 0
+	case zapcore.Float64Type:
+		return math.Float64frombits(uint64(f.Integer)) == 0
+	case zapcore.Float32Type:
+		return math.Float32frombits(uint32(f.Integer)) == 0
+	case zapcore.Complex128Type:
+		c, _ := f.Interface.(complex128)
+		return c == 0
+	case zapcore.Complex64Type:
+		c, _ := f.Interface.(complex64)
+		return c == 0
+	case zapcore.TimeFullType:
+		t, _ := f.Interface.(time.Time)
+		return t.IsZero()
+	default:
+		return false
+	}
+}
@@ -0,0 +1,83 @@
+package flash
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tj/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type nopWriteCore struct {
+	zapcore.Core
+}
+
+func (nopWriteCore) Enabled(zapcore.Level) bool                 { return true }
+func (nopWriteCore) Write(zapcore.Entry, []zapcore.Field) error { return nil }
+
+func TestSkipFieldsAllocs(t *testing.T) {
+	core := &transformCore{Core: nopWriteCore{zapcore.NewNopCore()}, t: newSkipFields([]string{"secret"})}
+	fields := []zapcore.Field{zap.String("secret", "xyz"), zap.String("user", "john")}
+	e := zapcore.Entry{Message: "info"}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		_ = core.Write(e, fields)
+	})
+	assert.Zero(t, allocs)
+}
+
+func BenchmarkSkipFields(b *testing.B) {
+	core := &transformCore{Core: nopWriteCore{zapcore.NewNopCore()}, t: newSkipFields([]string{"secret", "token"})}
+	fields := []zapcore.Field{
+		zap.String("secret", "xyz"),
+		zap.String("token", "abc"),
+		zap.String("user", "john"),
+		zap.Int("count", 42),
+	}
+	e := zapcore.Entry{Message: "info"}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		_ = core.Write(e, fields)
+	}
+}
+
+func TestOmitEmpty(t *testing.T) {
+	var nilPtr *int
+
+	tt := []struct {
+		field zapcore.Field
+		empty bool
+		zero  bool
+	}{
+		{zap.String("k", ""), true, true},
+		{zap.String("k", "v"), false, false},
+		{zap.Binary("k", nil), true, true},
+		{zap.ByteString("k", []byte("v")), false, false},
+		{zap.Any("k", nil), true, true},
+		{zap.Any("k", nilPtr), true, true},
+		{zap.Strings("k", nil), true, true},
+		{zap.Strings("k", []string{}), true, true},
+		{zap.Strings("k", []string{"v"}), false, false},
+		{zap.Any("k", map[string]int{}), true, true},
+		{zap.Reflect("k", struct{}{}), false, false},
+		{zap.Int("k", 0), false, true},
+		{zap.Int("k", 1), false, false},
+		{zap.Float64("k", 0), false, true},
+		{zap.Float32("k", 0.5), false, false},
+		{zap.Bool("k", false), false, true},
+		{zap.Duration("k", 0), false, true},
+		{zap.Time("k", time.Time{}), false, true},
+	}
+
+	for _, tc := range tt {
+		_, keep := omitEmpty(false)(tc.field)
+		assert.Equal(t, !tc.empty, keep, "empty %#v", tc.field)
+
+		_, keep = omitEmpty(true)(tc.field)
+		assert.Equal(t, !tc.zero, keep, "zero %#v", tc.field)
+	}
+}
@@ -2,11 +2,20 @@
 package flash
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
 	"net/url"
 	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/mattn/go-isatty"
 	"github.com/prometheus/client_golang/prometheus"
@@ -30,13 +39,69 @@ const (
 	LogFmt
 )
 
+// nolint: gochecknoglobals
+var encoderNames = map[EncoderType]string{
+	Console: "console",
+	JSON:    "json",
+	LogFmt:  "logfmt",
+}
+
+// ParseEncoder parses the name of an encoder type. The names are `console`, `json` and
+// `logfmt`, case-insensitively.
+func ParseEncoder(s string) (EncoderType, error) {
+	for e, name := range encoderNames {
+		if strings.EqualFold(s, name) {
+			return e, nil
+		}
+	}
+
+	return Console, fmt.Errorf("invalid encoder %q", s)
+}
+
+// String returns the name of the encoder type.
+func (e EncoderType) String() string {
+	if name, ok := encoderNames[e]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("EncoderType(%d)", int(e))
+}
+
 // Logger is the flash logger which embeds a `zap.SugaredLogger`.
+//
+// The `DPanic` methods of the embedded logger log at `DPanicLevel` and only panic, if
+// the logger is configured WithDPanicPanics.
 type Logger struct {
 	*zap.SugaredLogger
+	skipped           *zap.SugaredLogger
+	base              *zap.Logger
+	ring              *ringBuffer
+	closeSinks        func()
+	sinks             []string
+	closeOnce         sync.Once
+	disableCaller     bool
 	atom              zap.AtomicLevel
 	m                 sync.Mutex
 	currentLevel      zapcore.Level
 	disableStackTrace bool
+	beforeDisable     *levels
+	temporary         []*temporaryLevel
+	beforeTemporary   levels
+	files             []string
+	teeFiles          []string
+	journaldSocket    string
+	memoryBuffer      *memoryBuffer
+	unfiltered        *zap.Logger
+	auditAtom         zap.AtomicLevel
+	contextFields     []func(context.Context) []interface{}
+	requestIDKey      string
+	events            *eventCounter
+}
+
+// levels holds the levels of a logger to restore them.
+type levels struct {
+	level        zapcore.Level
+	currentLevel zapcore.Level
 }
 
 // Option configures zap.Config.
@@ -46,6 +111,15 @@ type Option func(c *config)
 func WithEncoder(e EncoderType) Option {
 	return func(c *config) {
 		c.encoder = e
+		c.encoderErr = nil
+	}
+}
+
+// WithEncoderString configures the zap encoder by its name, see ParseEncoder. NewE
+// returns an error, if the name is invalid.
+func WithEncoderString(s string) Option {
+	return func(c *config) {
+		c.encoder, c.encoderErr = ParseEncoder(s)
 	}
 }
 
@@ -56,6 +130,43 @@ func WithColor() Option {
 	}
 }
 
+// WithLevelColorFunc enables color output with the escape sequences returned by fn for
+// each level instead of the basic ANSI colors, for example `\x1b[38;2;255;85;85m` for a
+// truecolor red. The level is reset with `\x1b[0m`. Levels for which fn returns an empty
+// string are not colored.
+func WithLevelColorFunc(fn func(zapcore.Level) string) Option {
+	return func(c *config) {
+		c.enableColor = true
+		c.levelColor = fn
+	}
+}
+
+// WithColorFromLevel enables color output only for levels at or above level, for example
+// to color only warnings and errors.
+func WithColorFromLevel(level zapcore.Level) Option {
+	return func(c *config) {
+		c.enableColor = true
+		c.colorLevel = &level
+	}
+}
+
+// WithAlignedConsole pads the level and caller columns of the console encoder to fixed
+// widths, so that the messages of consecutive lines are aligned.
+func WithAlignedConsole() Option {
+	return func(c *config) {
+		c.alignedConsole = true
+	}
+}
+
+// WithFixedLevelWidth pads the level column of the console encoder with spaces to n
+// characters, for example `INFO ` for a width of 5. Color codes do not count towards the
+// width. WithAlignedConsole takes precedence.
+func WithFixedLevelWidth(n int) Option {
+	return func(c *config) {
+		c.levelWidth = n
+	}
+}
+
 // WithoutCaller stops annotating logs with the calling function's file
 // name and line number.
 func WithoutCaller() Option {
@@ -64,6 +175,29 @@ func WithoutCaller() Option {
 	}
 }
 
+// WithCallerFromLevel annotates only logs at or above level with the caller. WithoutCaller
+// takes precedence and disables the caller for all levels.
+func WithCallerFromLevel(level zapcore.Level) Option {
+	return func(c *config) {
+		c.callerLevel = &level
+	}
+}
+
+// WithCallerOnError annotates only logs at or above `ErrorLevel` with the caller. It is a
+// shortcut for WithCallerFromLevel(zapcore.ErrorLevel).
+func WithCallerOnError() Option {
+	return WithCallerFromLevel(zapcore.ErrorLevel)
+}
+
+// WithCallerModuleRelative annotates logs with the caller path relative to the module
+// root directory instead of the short `package/file.go` path. Callers outside of root are
+// annotated with the short path.
+func WithCallerModuleRelative(root string) Option {
+	return func(c *config) {
+		c.callerRoot = root
+	}
+}
+
 // WithSinks changes the default zap `stderr` sink.
 func WithSinks(sinks ...string) Option {
 	return func(c *config) {
@@ -71,6 +205,25 @@ func WithSinks(sinks ...string) Option {
 	}
 }
 
+// WithFallbackSink configures the sinks to use, if the configured sinks cannot be opened.
+// Instead of panicking, the logger falls back to these sinks and writes a warning to the
+// error output or the writer of WithConstructionErrorWriter.
+func WithFallbackSink(sinks ...string) Option {
+	return func(c *config) {
+		c.fallbackSinks = sinks
+	}
+}
+
+// WithConstructionErrorWriter writes the diagnostics of flash while creating the logger,
+// for example about ignored duplicate sinks or sinks replaced by WithFallbackSink, to w
+// instead of the error output, which is stderr. Tests and embedding applications can use
+// it to capture or discard them.
+func WithConstructionErrorWriter(w io.Writer) Option {
+	return func(c *config) {
+		c.constructionErr = zapcore.AddSync(w)
+	}
+}
+
 // WithDebug enables or disables `DebugLevel`.
 func WithDebug(debug bool) Option {
 	return func(c *config) {
@@ -78,6 +231,14 @@ func WithDebug(debug bool) Option {
 	}
 }
 
+// WithVerbosity sets the initial level by a numeric verbosity, see Logger.SetVerbosity.
+// WithDebug(true) takes precedence.
+func WithVerbosity(v int) Option {
+	return func(c *config) {
+		c.verbosity = &v
+	}
+}
+
 // WithStacktrace completely enables automatic stacktrace capturing. Stacktraces
 // are captured on `ErrorLevel` and above when in debug mode. When not in debug mode,
 // only `FatalLevel` messages contain stacktraces.
@@ -87,13 +248,16 @@ func WithStacktrace() Option {
 	}
 }
 
-// WithPrometheus registers a prometheus log message counter.
+// WithPrometheus registers a prometheus log message counter and a histogram of the number
+// of fields per entry.
 //
 // The created metrics are of the form:
 //
-//	<appName>_log_messages_total{level="info"} 4
+//	<appName>_log_messages_total{component="db",level="info"} 4
+//	<appName>_log_entry_fields_bucket{le="8"} 4
 //
-// If appName is an empty string `flash` is used.
+// The component is the name of the logger, which is empty for unnamed loggers. If appName
+// is an empty string `flash` is used.
 func WithPrometheus(appName string, registry prometheus.Registerer) Option {
 	return func(c *config) {
 		name := appName
@@ -101,253 +265,2003 @@ func WithPrometheus(appName string, registry prometheus.Registerer) Option {
 			name = "flash"
 		}
 
+		// WithMetricsOnly registers the same metrics
+		if c.prometheusRegistry == registry && c.prometheusName == name {
+			return
+		}
+
+		c.prometheusRegistry = registry
+		c.prometheusName = name
+
 		counter := prometheus.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: fmt.Sprintf("%s_log_messages_total", name),
-				Help: "How many log messages created, partitioned by log level.",
+				Help: "How many log messages created, partitioned by log level and component.",
 			},
-			[]string{"level"},
+			[]string{"level", "component"},
 		)
 		registry.MustRegister(counter)
 
-		c.hook = func(e zapcore.Entry) error {
-			counter.WithLabelValues(e.Level.String()).Inc()
+		fields := prometheus.NewHistogram(
+			prometheus.HistogramOpts{
+				Name:    fmt.Sprintf("%s_log_entry_fields", name),
+				Help:    "How many fields log entries have.",
+				Buckets: prometheus.ExponentialBuckets(1, 2, 8),
+			},
+		)
+		registry.MustRegister(fields)
+
+		c.fieldCount = fields
+
+		c.hooks = append(c.hooks, func(e zapcore.Entry) error {
+			if c.prometheusMinLevel != nil && e.Level < *c.prometheusMinLevel {
+				return nil
+			}
+
+			counter.WithLabelValues(e.Level.String(), e.LoggerName).Inc()
+
 			return nil
-		}
+		})
 	}
 }
 
-// WithFile configures the logger to log output into a file.
-func WithFile(cfg FileConfig) Option {
+// WithMetricsOnly configures the logger to only count the entries with a prometheus
+// counter like WithPrometheus. The entries are not encoded and written and the caller and
+// stacktraces are not computed. The options changing the fields or the written entries,
+// like sampling, do not apply. WithPrometheus with the same appName and registry is
+// ignored.
+func WithMetricsOnly(appName string, registry prometheus.Registerer) Option {
 	return func(c *config) {
-		c.fileConfig = &cfg
-	}
-}
+		WithPrometheus(appName, registry)(c)
 
-// WithoutTimestamps configures the logger to log without timestamps.
-func WithoutTimestamps() Option {
-	return func(c *config) {
-		c.disableTimestamps = true
+		c.metricsOnly = true
+		c.disableCaller = true
+		c.disableStacktrace = true
+		c.output = zapcore.AddSync(ioutil.Discard)
 	}
 }
 
-// FileConfig holds the configuration for logging into a file. The size is in Megabytes and
-// MaxAge is in days. If compress is true the rotated files are compressed.
-type FileConfig struct {
-	Path       string
-	MaxSize    int
-	MaxBackups int
-	MaxAge     int
-	Compress   bool
+// discardCore is a zapcore.Core which discards all entries. It checks the enabled entries,
+// so that the hooks are called.
+type discardCore struct {
+	zapcore.LevelEnabler
 }
 
-// New creates a new Logger. If no options are specified, stacktraces and color output are disabled and
-// the confgured level is `InfoLevel`.
-func New(opts ...Option) *Logger {
-	atom := zap.NewAtomicLevelAt(zap.InfoLevel)
+func (c discardCore) With([]zapcore.Field) zapcore.Core {
+	return c
+}
 
-	cfg := config{
-		disableStacktrace: true,
-		encoder:           Console,
+func (c discardCore) Check(e zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(e.Level) {
+		return ce.AddCore(e, c)
 	}
 
-	// set encoder to json and disable color output when no terminal is detected
-	// and encoder is not LogFmt
-	if !isatty.IsTerminal(os.Stdout.Fd()) && cfg.encoder != LogFmt {
-		cfg.encoder = JSON
-		cfg.enableColor = false
-	}
+	return ce
+}
 
-	for _, opt := range opts {
-		opt(&cfg)
-	}
+func (discardCore) Write(zapcore.Entry, []zapcore.Field) error {
+	return nil
+}
 
-	if cfg.encoder != Console {
-		cfg.enableColor = false
-	}
+func (discardCore) Sync() error {
+	return nil
+}
 
-	zapConfig := genZapConfig(cfg)
-	zapConfig.Level = atom
+// WithPrometheusLastLog registers a prometheus gauge with the Unix timestamp in seconds of
+// the last log entry, for example to alert when a service stops logging:
+//
+//	<appName>_last_log_timestamp_seconds
+//
+// If appName is an empty string `flash` is used.
+func WithPrometheusLastLog(appName string, registry prometheus.Registerer) Option {
+	return func(c *config) {
+		name := appName
+		if name == "" {
+			name = "flash"
+		}
 
-	var err error
+		gauge := prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: fmt.Sprintf("%s_last_log_timestamp_seconds", name),
+				Help: "Unix timestamp of the last log entry.",
+			},
+		)
+		registry.MustRegister(gauge)
 
-	l, err := zapConfig.Build()
-	if err != nil {
-		panic(fmt.Sprintf("could not create zap logger: %s", err))
+		c.hooks = append(c.hooks, func(e zapcore.Entry) error {
+			gauge.Set(float64(e.Time.UnixNano()) / float64(time.Second))
+			return nil
+		})
 	}
+}
+
+// WithPrometheusLatency registers a prometheus histogram of the duration of encoding and
+// writing log entries in seconds:
+//
+//	<appName>_log_write_duration_seconds
+//
+// If buckets is nil, the default prometheus buckets are used. If appName is an empty string
+// `flash` is used.
+func WithPrometheusLatency(appName string, registry prometheus.Registerer, buckets []float64) Option {
+	return func(c *config) {
+		name := appName
+		if name == "" {
+			name = "flash"
+		}
 
-	stackTraceLevel := zap.FatalLevel
+		histogram := prometheus.NewHistogram(
+			prometheus.HistogramOpts{
+				Name:    fmt.Sprintf("%s_log_write_duration_seconds", name),
+				Help:    "How long encoding and writing log entries takes.",
+				Buckets: buckets,
+			},
+		)
+		registry.MustRegister(histogram)
 
-	if cfg.isDebug {
-		atom.SetLevel(zap.DebugLevel)
-		stackTraceLevel = zap.ErrorLevel
+		c.writeLatency = histogram
 	}
+}
 
-	// fix level for stack traces
-	if !cfg.disableStacktrace {
-		l = l.WithOptions(zap.AddStacktrace(stackTraceLevel))
+// latencyCore is a zapcore.Core wrapper which observes the duration of writes.
+type latencyCore struct {
+	zapcore.Core
+	observer prometheus.Observer
+}
+
+func (c *latencyCore) With(fields []zapcore.Field) zapcore.Core {
+	return &latencyCore{
+		Core:     c.Core.With(fields),
+		observer: c.observer,
 	}
+}
 
-	if cfg.hook != nil {
-		l = l.WithOptions(zap.Hooks(cfg.hook))
+func (c *latencyCore) Check(e zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(e.Level) {
+		return ce.AddCore(e, c)
 	}
 
-	defer func() {
-		_ = l.Sync()
-	}()
+	return ce
+}
 
-	return &Logger{
-		SugaredLogger:     l.Sugar(),
-		atom:              atom,
-		disableStackTrace: cfg.disableStacktrace,
-	}
+func (c *latencyCore) Write(e zapcore.Entry, fields []zapcore.Field) error {
+	start := time.Now()
+	err := c.Core.Write(e, fields)
+	c.observer.Observe(time.Since(start).Seconds())
+
+	return err
 }
 
-// SetDebug enables or disables `DebugLevel`.
-func (l *Logger) SetDebug(d bool) {
-	level := zap.DebugLevel
-	stackTraceLevel := zap.ErrorLevel
+// fieldCounter is a transformer which observes the number of fields of entries, including
+// the fields added with With.
+type fieldCounter struct {
+	observer prometheus.Observer
+	minLevel *zapcore.Level
+	n        int
+}
 
-	if !d {
-		l.m.Lock()
-		level = l.currentLevel
-		l.m.Unlock()
+func (t *fieldCounter) with(fields []zapcore.Field) ([]zapcore.Field, transformer) {
+	return fields, &fieldCounter{
+		observer: t.observer,
+		minLevel: t.minLevel,
+		n:        t.n + len(fields),
+	}
+}
 
-		stackTraceLevel = zap.FatalLevel
+func (t *fieldCounter) transform(e zapcore.Entry, dst, fields []zapcore.Field) (zapcore.Entry, []zapcore.Field) {
+	if t.minLevel == nil || e.Level >= *t.minLevel {
+		t.observer.Observe(float64(t.n + len(fields)))
 	}
 
-	l.atom.SetLevel(level)
-	l.stackTrace(stackTraceLevel)
+	return e, append(dst, fields...)
 }
 
-// Disable disables (nearly) all output. Only `FatalLevel` errors are logged.
-func (l *Logger) Disable() {
-	l.m.Lock()
-	l.currentLevel = zapcore.FatalLevel
-	l.m.Unlock()
-	l.atom.SetLevel(zap.FatalLevel)
+// WithPrometheusMinLevel configures the counter of WithPrometheus to count only entries
+// at or above level. By default, entries of all levels are counted.
+func WithPrometheusMinLevel(level zapcore.Level) Option {
+	return func(c *config) {
+		c.prometheusMinLevel = &level
+	}
 }
 
-// SetLevel sets the chosen level. If stacktraces are enabled, it adjusts stacktrace levels accordingly.
-func (l *Logger) SetLevel(level zapcore.Level) {
-	l.m.Lock()
-	oldLevel := l.currentLevel
-	l.currentLevel = level
-	l.m.Unlock()
-	l.atom.SetLevel(level)
+// alertQueueSize is the number of entries WithAlert buffers for its notify function.
+const alertQueueSize = 100
 
-	if level == zap.DebugLevel {
-		l.stackTrace(zap.ErrorLevel)
-		return
-	}
+// WithAlert calls notify for all entries at or above minLevel. The notify function is
+// called by a single worker goroutine and panics in notify are recovered, so a misbehaving
+// notify function cannot block or crash logging. Up to 100 entries are queued for the
+// worker, further entries are dropped until the worker catches up. Entries at or above
+// DPanic are passed to notify synchronously, before the logger panics or exits.
+func WithAlert(minLevel zapcore.Level, notify func(zapcore.Entry)) Option {
+	return func(c *config) {
+		a := &alerter{notify: notify}
 
-	if oldLevel == zap.DebugLevel && level != zap.DebugLevel {
-		l.stackTrace(zap.FatalLevel)
+		c.hooks = append(c.hooks, func(e zapcore.Entry) error {
+			if e.Level < minLevel {
+				return nil
+			}
+
+			a.alert(e)
+
+			return nil
+		})
 	}
 }
 
-// Get returns the embedded zap.Logger
-func (l *Logger) Get() *zap.SugaredLogger {
-	return l.SugaredLogger
+// alerter passes entries to notify. The worker goroutine is started with the first entry.
+type alerter struct {
+	notify  func(zapcore.Entry)
+	once    sync.Once
+	entries chan zapcore.Entry
 }
 
-func (l *Logger) stackTrace(lvl zapcore.Level) {
-	if l.disableStackTrace {
+func (a *alerter) alert(e zapcore.Entry) {
+	if e.Level >= zapcore.DPanicLevel {
+		a.call(e)
 		return
 	}
 
-	l.m.Lock()
-	l.SugaredLogger = l.Get().Desugar().WithOptions(zap.AddStacktrace(lvl)).Sugar()
-	l.m.Unlock()
-}
+	a.once.Do(func() {
+		a.entries = make(chan zapcore.Entry, alertQueueSize)
 
-type config struct {
-	enableColor       bool
-	disableCaller     bool
-	disableStacktrace bool
-	disableTimestamps bool
-	isDebug           bool
-	hook              func(zapcore.Entry) error
-	sinks             []string
-	fileConfig        *FileConfig
-	encoder           EncoderType
-}
+		go func() {
+			for e := range a.entries {
+				a.call(e)
+			}
+		}()
+	})
 
-func (cfg FileConfig) sinkURI() string {
-	return fmt.Sprintf("%s://localhost/%s", lumberjackSinkURIPrefix, cfg.Path)
+	select {
+	case a.entries <- e:
+	default:
+	}
 }
 
-func pathFromURI(u *url.URL) string {
-	return strings.Replace(u.Path, "/", "", 1)
+func (a *alerter) call(e zapcore.Entry) {
+	defer func() {
+		_ = recover()
+	}()
+
+	a.notify(e)
 }
 
-type lumberjackSink struct {
-	*lumberjack.Logger
+// WithFile configures the logger to log output into a file. All loggers of the process
+// which log into the same file share it, including its rotation, so they must use the
+// same FileConfig. Creating a logger with a different FileConfig for a path already in
+// use fails, until all loggers using the file are closed.
+func WithFile(cfg FileConfig) Option {
+	return func(c *config) {
+		c.fileConfig = &cfg
+	}
 }
 
-// Sync implements zap.Sink. The remaining methods are implemented
-// by the embedded *lumberjack.Logger.
-func (lumberjackSink) Sync() error { return nil }
+// WithoutTimestamps configures the logger to log without timestamps. It takes precedence
+// over a time key configured with WithKeys, regardless of the order of the options.
+func WithoutTimestamps() Option {
+	return func(c *config) {
+		c.disableTimestamps = true
+	}
+}
 
-func (c config) registerFileSink() error {
-	return zap.RegisterSink(lumberjackSinkURIPrefix, func(u *url.URL) (zap.Sink, error) {
-		return lumberjackSink{
-			Logger: &lumberjack.Logger{
-				Filename:   pathFromURI(u),
-				MaxSize:    c.fileConfig.MaxSize,
-				MaxAge:     c.fileConfig.MaxAge,
-				MaxBackups: c.fileConfig.MaxBackups,
-				Compress:   c.fileConfig.Compress,
-			},
-		}, nil
-	})
+// WithSkipKeys configures the logger to drop all fields with the given keys.
+func WithSkipKeys(keys ...string) Option {
+	return func(c *config) {
+		c.skipKeys = append(c.skipKeys, keys...)
+	}
 }
 
-func genZapConfig(cfg config) zap.Config {
-	zapConfig := zap.NewProductionConfig()
-	zapConfig.DisableStacktrace = cfg.disableStacktrace
-	zapConfig.Sampling = nil
-	zapConfig.DisableCaller = cfg.disableCaller
-	zapConfig.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
-	zapConfig.EncoderConfig.EncodeDuration = zapcore.StringDurationEncoder
-	zapConfig.EncoderConfig.EncodeLevel = zapcore.CapitalLevelEncoder
+// WithSeverityNumber adds the OpenTelemetry severity number of the level with the given
+// key to every entry, for example 9 for `InfoLevel` and 17 for `ErrorLevel`.
+func WithSeverityNumber(key string) Option {
+	return func(c *config) {
+		c.severityKey = key
+	}
+}
 
-	switch cfg.encoder {
-	case Console:
-		zapConfig.Encoding = "console"
-	case JSON:
-		zapConfig.Encoding = "json"
-	case LogFmt:
-		zapConfig.Encoding = "logfmt"
-		_ = zap.RegisterEncoder("logfmt", func(cfg zapcore.EncoderConfig) (zapcore.Encoder, error) {
-			return zaplogfmt.NewEncoder(cfg), nil
-		})
+// WithOmitEmpty configures the logger to drop fields with empty values: empty strings and
+// byte strings, nil values, nil pointers and empty slices, maps and arrays. Zero numbers
+// are kept, unless WithOmitZero is configured.
+func WithOmitEmpty() Option {
+	return func(c *config) {
+		c.omitEmpty = true
 	}
+}
 
-	// no colors when logging to file
-	if cfg.enableColor && cfg.fileConfig == nil {
-		zapConfig.EncoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
+// WithOmitZero configures the logger to drop fields with zero numbers, false booleans and
+// zero durations and times in addition to the empty fields dropped by WithOmitEmpty. It
+// implies WithOmitEmpty.
+func WithOmitZero() Option {
+	return func(c *config) {
+		c.omitEmpty = true
+		c.omitZero = true
 	}
+}
 
-	if len(cfg.sinks) > 0 {
-		zapConfig.OutputPaths = cfg.sinks
+// WithRenameKeys configures the logger to rename the keys of fields found in mapping to
+// the mapped keys, for example during migrations of field names. WithSkipKeys and
+// WithAllowKeys apply to the keys before they are renamed. The keys of the message, level,
+// time and caller are configured with WithKeys.
+func WithRenameKeys(mapping map[string]string) Option {
+	return func(c *config) {
+		if c.renameKeys == nil {
+			c.renameKeys = make(map[string]string, len(mapping))
+		}
+
+		for from, to := range mapping {
+			c.renameKeys[from] = to
+		}
 	}
+}
 
-	if cfg.disableTimestamps {
-		zapConfig.EncoderConfig.TimeKey = ""
+// WithAllowKeys configures the logger to drop all fields, which do not have one of the
+// given keys. The message, level, time and caller of entries are always kept. Combined with
+// WithSkipKeys, only allowed fields, which are not skipped, are logged.
+func WithAllowKeys(keys ...string) Option {
+	return func(c *config) {
+		c.allowKeys = append(c.allowKeys, keys...)
 	}
+}
+
+// WithFieldTransform configures the logger to replace all fields with the given key by
+// the result of fn, for example to hash or normalize values. Multiple transforms for the
+// same key are applied in the order of the options.
+func WithFieldTransform(key string, fn func(zapcore.Field) zapcore.Field) Option {
+	return func(c *config) {
+		if c.transforms == nil {
+			c.transforms = map[string][]func(zapcore.Field) zapcore.Field{}
+		}
+
+		c.transforms[key] = append(c.transforms[key], fn)
+	}
+}
+
+// WithAnnotations adds the annotations as fields to every log entry. Environment
+// variable references like `${POD_NAME}` in the values are expanded. Annotations
+// with an empty value after expansion are dropped.
+func WithAnnotations(annotations map[string]string) Option {
+	return func(c *config) {
+		keys := make([]string, 0, len(annotations))
+		for k := range annotations {
+			keys = append(keys, k)
+		}
+
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			v := os.ExpandEnv(annotations[k])
+			if v == "" {
+				continue
+			}
+
+			c.fields = append(c.fields, zap.String(k, v))
+		}
+	}
+}
+
+// WithAtomicLevel configures the logger to use the given level. The level can be shared
+// between multiple loggers. If the level is debug on creation, errors are logged with
+// stacktraces like in debug mode.
+func WithAtomicLevel(atom zap.AtomicLevel) Option {
+	return func(c *config) {
+		c.atom = &atom
+	}
+}
+
+// WithKeys configures the keys used for the entry fields like time, level and message.
+func WithKeys(keys Keys) Option {
+	return func(c *config) {
+		c.keys = keys
+	}
+}
+
+// Keys holds the keys used for the entry fields. Empty keys keep the default key. The
+// time key is ignored if timestamps are disabled with WithoutTimestamps.
+type Keys struct {
+	Time       string
+	Level      string
+	Name       string
+	Caller     string
+	Message    string
+	Stacktrace string
+}
+
+func (k Keys) apply(enc *zapcore.EncoderConfig) {
+	for _, key := range []struct {
+		value string
+		dst   *string
+	}{
+		{k.Time, &enc.TimeKey},
+		{k.Level, &enc.LevelKey},
+		{k.Name, &enc.NameKey},
+		{k.Caller, &enc.CallerKey},
+		{k.Message, &enc.MessageKey},
+		{k.Stacktrace, &enc.StacktraceKey},
+	} {
+		if key.value != "" {
+			*key.dst = key.value
+		}
+	}
+}
+
+// WithStructuredStacktrace configures the logger to log stacktraces as an array of frames
+// with function, file and line. It only affects the JSON encoder.
+func WithStructuredStacktrace() Option {
+	return func(c *config) {
+		c.structuredStacktrace = true
+	}
+}
+
+// WithErrorKey configures the key for errors added with zap.Error or passed to a
+// sugared logger without a key, like `l.Errorw("failed", err)`. The default key is `error`.
+func WithErrorKey(key string) Option {
+	return func(c *config) {
+		c.errorKey = key
+	}
+}
+
+// WithSampling configures sampling per level. Levels without a SampleRate are not sampled.
+//
+//	flash.WithSampling(map[zapcore.Level]flash.SampleRate{
+//		zapcore.DebugLevel: {Initial: 10, Thereafter: 100},
+//	})
+func WithSampling(rates map[zapcore.Level]SampleRate) Option {
+	return func(c *config) {
+		c.sampling = rates
+	}
+}
+
+// WithSchemaVersion adds the version of the log schema as `log_schema` field to every
+// log entry. It allows consumers to detect changes of the log format.
+func WithSchemaVersion(version string) Option {
+	return func(c *config) {
+		c.fields = append(c.fields, zap.String("log_schema", version))
+	}
+}
+
+// WithRingBuffer configures the logger to keep the last n encoded log entries in memory.
+// The entries can be retrieved with Tail, for example to dump them after a crash.
+func WithRingBuffer(n int) Option {
+	return func(c *config) {
+		c.ringSize = n
+	}
+}
+
+// WithLambdaDefaults configures the logger for serverless environments like AWS Lambda,
+// where the output is collected by the platform: JSON encoding, no caller, UTC
+// timestamps in RFC3339 format and a `requestId` field added by Ctx, if the context
+// stores a request ID with NewRequestIDContext. Options following WithLambdaDefaults
+// override these defaults.
+func WithLambdaDefaults() Option {
+	return func(c *config) {
+		c.encoder = JSON
+		c.enableColor = false
+		c.disableCaller = true
+		c.timeEncoder = utcRFC3339TimeEncoder
+		c.requestIDKey = "requestId"
+	}
+}
+
+func utcRFC3339TimeEncoder(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
+	enc.AppendString(t.UTC().Format(time.RFC3339))
+}
+
+// WithDeferredField adds a field to every log entry, whose value is computed by fn on the
+// first log call and cached thereafter. It is intended for expensive constant values, which
+// should not block New. The first log call computes the value while writing its entry, and
+// concurrent log calls wait for it, so fn should not block for long, and must not log with
+// the same logger, which deadlocks.
+func WithDeferredField(key string, fn func() interface{}) Option {
+	return func(c *config) {
+		c.deferredFields = append(c.deferredFields, &deferredField{key: key, fn: fn})
+	}
+}
+
+// WithDurationEncoder configures the encoder for duration fields. The default encodes
+// durations as strings like `1.5s`.
+func WithDurationEncoder(enc zapcore.DurationEncoder) Option {
+	return func(c *config) {
+		c.durationEncoder = enc
+	}
+}
+
+// WithSecondsDuration encodes duration fields as floating-point numbers of seconds, for
+// example `1.5` for 1500ms. It is a shortcut for WithDurationEncoder.
+func WithSecondsDuration() Option {
+	return WithDurationEncoder(zapcore.SecondsDurationEncoder)
+}
+
+// WithMillisDuration encodes duration fields as integer numbers of milliseconds, for
+// example `1500` for 1.5s. It is a shortcut for WithDurationEncoder.
+func WithMillisDuration() Option {
+	return WithDurationEncoder(zapcore.MillisDurationEncoder)
+}
+
+// WithReflectedEncoder configures the encoder of fields added with zap.Any or zap.Reflect,
+// which cannot be encoded otherwise. The default uses encoding/json. The option has no
+// effect on the logfmt encoder.
+func WithReflectedEncoder(fn func(io.Writer) zapcore.ReflectedEncoder) Option {
+	return func(c *config) {
+		c.reflectedEncoder = fn
+	}
+}
+
+// WithTimeRounding rounds the timestamps down to a multiple of d before they are encoded
+// with the configured time encoder, for example to the second or minute. Time fields are
+// rounded as well, unless they are encoded by WithTimeEncoderForFields.
+func WithTimeRounding(d time.Duration) Option {
+	return func(c *config) {
+		c.timeRounding = d
+	}
+}
+
+// roundingTimeEncoder returns a time encoder, which rounds the time down to a multiple
+// of d before encoding it with enc.
+func roundingTimeEncoder(d time.Duration, enc zapcore.TimeEncoder) zapcore.TimeEncoder {
+	return func(t time.Time, pae zapcore.PrimitiveArrayEncoder) {
+		enc(t.Truncate(d), pae)
+	}
+}
+
+// WithTimeEncoderForFields configures the encoder for time fields. The timestamp of the
+// log entry is not affected.
+func WithTimeEncoderForFields(enc zapcore.TimeEncoder) Option {
+	return func(c *config) {
+		c.fieldTimeEncoder = enc
+	}
+}
+
+// WithoutInitialSync disables the sync of the sinks at the end of New. It avoids the
+// sync, which is not supported by all sinks, in short-lived processes.
+func WithoutInitialSync() Option {
+	return func(c *config) {
+		c.disableInitialSync = true
+	}
+}
+
+// WithSamplingHook configures a function, which is called with every sampling decision of
+// entries with a level configured WithSampling. The sampled argument is true, if the entry
+// is logged and false, if it is dropped.
+func WithSamplingHook(fn func(entry zapcore.Entry, sampled bool)) Option {
+	return func(c *config) {
+		c.samplingHook = fn
+	}
+}
+
+// WithStartupLog configures the logger to log its effective configuration like level,
+// encoder and sinks with a single info entry, when it is created.
+func WithStartupLog() Option {
+	return func(c *config) {
+		c.startupLog = true
+	}
+}
+
+// WithDPanicPanics configures whether logging at `DPanicLevel` panics after the entry
+// is written. By default `DPanic` only logs, like a zap production logger.
+func WithDPanicPanics(panics bool) Option {
+	return func(c *config) {
+		c.dpanicPanics = panics
+	}
+}
+
+// WithGlobal replaces the global loggers of zap.L and zap.S with the new logger. There is
+// only one global logger, if multiple loggers are created WithGlobal, the last one is used.
+// Level changes of the logger apply to the global loggers, stacktrace level changes by
+// SetDebug or SetLevel do not.
+func WithGlobal() Option {
+	return func(c *config) {
+		c.global = true
+	}
+}
+
+// WithNoFatalExit configures logging at `FatalLevel` to only log the entry and return
+// instead of calling os.Exit, so that a Fatal call in a request handler does not stop a
+// server. Use it with care: code calling Fatal does not expect to continue and genuinely
+// fatal conditions are masked.
+func WithNoFatalExit() Option {
+	return func(c *config) {
+		c.noFatalExit = true
+	}
+}
+
+// noExitHook is a zapcore.CheckWriteHook doing nothing after fatal entries. zap replaces
+// zapcore.WriteThenNoop with os.Exit for fatal entries.
+type noExitHook struct{}
+
+func (noExitHook) OnWrite(*zapcore.CheckedEntry, []zapcore.Field) {}
+
+// WithCoreWrapper wraps the core built by flash with fn. Multiple wrappers are applied
+// in the order of the options, the last one being the outermost. The wrapped core sees
+// all entries before the hooks like WithPrometheus or WithAlert, so entries dropped by the
+// wrapper are not passed to the hooks. Stacktraces and callers are captured by the logger
+// and are already part of the entries passed to the wrapped core.
+func WithCoreWrapper(fn func(zapcore.Core) zapcore.Core) Option {
+	return func(c *config) {
+		c.coreWrappers = append(c.coreWrappers, fn)
+	}
+}
+
+// WithOutput writes the entries to w instead of the sinks.
+func WithOutput(w zapcore.WriteSyncer) Option {
+	return func(c *config) {
+		c.output = w
+	}
+}
+
+// WithTee additionally writes all entries enabled for the logger to core, like to the
+// sinks. Fields are transformed and filtered for core like for the sinks.
+func WithTee(core zapcore.Core) Option {
+	return func(c *config) {
+		c.tee = core
+	}
+}
+
+// FileConfig holds the configuration for logging into a file. The size is in Megabytes and
+// MaxAge is in days. If compress is true the rotated files are compressed.
+//
+// Already existing uncompressed backups are compressed in the background, when the file
+// is opened for the first write. If CompressExisting is true, the file is opened when the
+// logger is created, so existing backups are compressed immediately. CompressExisting
+// implies Compress.
+//
+// If SyncEachLine is true, the file is synced to disk after every log entry, at the cost
+// of throughput.
+type FileConfig struct {
+	Path             string
+	MaxSize          int
+	MaxBackups       int
+	MaxAge           int
+	Compress         bool
+	CompressExisting bool
+	SyncEachLine     bool
+
+	// utf16 is set by WithUTF16BOM
+	utf16 bool
+	// jsonArray is set by WithJSONArray
+	jsonArray bool
+}
+
+// New creates a new Logger. If no options are specified, stacktraces and color output are disabled and
+// the confgured level is `InfoLevel`. New panics, if the logger cannot be created.
+//
+// Buffered entries are only guaranteed to be written, if Sync or Close is called before
+// the process exits.
+func New(opts ...Option) *Logger {
+	l, err := NewE(opts...)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	return l
+}
+
+// NewE creates a new Logger like New, but returns an error instead of panicking, if the
+// logger cannot be created.
+func NewE(opts ...Option) (*Logger, error) {
+	cfg := config{
+		disableStacktrace: true,
+		encoder:           Console,
+	}
+
+	// set encoder to json and disable color output when no terminal is detected
+	// and encoder is not LogFmt
+	if !isatty.IsTerminal(os.Stdout.Fd()) && cfg.encoder != LogFmt {
+		cfg.encoder = JSON
+		cfg.enableColor = false
+	}
+
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	if cfg.utf16 {
+		for _, fc := range []*FileConfig{cfg.fileConfig, cfg.teeFile, cfg.debugFile} {
+			if fc != nil {
+				fc.utf16 = true
+			}
+		}
+	}
+
+	if cfg.jsonArray && cfg.fileConfig != nil {
+		cfg.fileConfig.jsonArray = true
+	}
+
+	if cfg.encoder != Console {
+		cfg.enableColor = false
+	}
+
+	atom := zap.NewAtomicLevelAt(zap.InfoLevel)
+	if cfg.atom != nil {
+		atom = *cfg.atom
+	}
+
+	if cfg.verbosity != nil {
+		atom.SetLevel(verbosityLevel(*cfg.verbosity))
+	}
+
+	currentLevel := atom.Level()
+
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	zapConfig, err := genZapConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	zapConfig.Level = atom
+
+	// the cores are enabled for the levels of the logger and of its audit loggers, each
+	// logger filters the entries by its own level
+	auditAtom := zap.NewAtomicLevelAt(zap.InfoLevel)
+	coreLevel := anyLevel{atom, auditAtom}
+
+	// with a debug file, debug entries are only written into the debug file
+	outputLevel := zapcore.LevelEnabler(coreLevel)
+	if cfg.debugFile != nil {
+		outputLevel = minLevel{LevelEnabler: coreLevel, min: zap.InfoLevel}
+	}
+
+	var sinkErr error
+
+	sinks := zapConfig.OutputPaths
+
+	errSink, _, err := zap.Open(zapConfig.ErrorOutputPaths...)
+	if err != nil {
+		return nil, fmt.Errorf("could not create zap logger: %w", err)
+	}
+
+	l, closeSinks, err := build(cfg, zapConfig, outputLevel, errSink)
+	if err != nil && len(cfg.fallbackSinks) > 0 {
+		sinkErr = err
+		zapConfig.OutputPaths = cfg.fallbackSinks
+		l, closeSinks, err = build(cfg, zapConfig, outputLevel, errSink)
+
+		if err == nil {
+			cfg.diagnose(errSink, "could not open sinks %s, using fallback sinks %s: %v",
+				strings.Join(sinks, ", "), strings.Join(cfg.fallbackSinks, ", "), sinkErr)
+		}
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("could not create zap logger: %w", err)
+	}
+
+	if cfg.isDebug {
+		atom.SetLevel(zap.DebugLevel)
+	}
+
+	// like in debug mode, errors have stacktraces if the level is debug by the verbosity
+	// or the atomic level
+	stackTraceLevel := stackTraceLevel(atom.Level())
+
+	// the files of WithConsoleAndFile and WithDebugFile are JSON, so the console
+	// alignment does not apply
+	fileEncoderConfig := zapConfig.EncoderConfig
+	fileEncoderConfig.EncodeCaller = fileCallerEncoder(cfg)
+	if cfg.fileTimeUTC && cfg.fileConfig == nil {
+		fileEncoderConfig.EncodeTime = utcTimeEncoder(fileEncoderConfig.EncodeTime)
+	}
+
+	if cfg.teeFile != nil {
+		fileCore, closeFile, err := newFileCore(*cfg.teeFile, fileEncoderConfig, outputLevel)
+		if err != nil {
+			closeSinks()
+			return nil, fmt.Errorf("could not create zap logger: %w", err)
+		}
+
+		l = l.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+			return zapcore.NewTee(core, fileCore)
+		}))
+
+		closeOutput := closeSinks
+		closeSinks = func() {
+			closeOutput()
+			closeFile()
+		}
+	}
+
+	if cfg.debugFile != nil {
+		debugCore, closeDebugFile, err := newFileCore(*cfg.debugFile, fileEncoderConfig, debugMode{atom})
+		if err != nil {
+			closeSinks()
+			return nil, fmt.Errorf("could not create zap logger: %w", err)
+		}
+
+		l = l.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+			return zapcore.NewTee(&enabledCore{Core: core}, &enabledCore{Core: debugCore})
+		}))
+
+		closeOutput := closeSinks
+		closeSinks = func() {
+			closeOutput()
+			closeDebugFile()
+		}
+	}
+
+	if cfg.tee != nil {
+		teeCore := &levelCore{Core: cfg.tee, level: outputLevel}
+
+		l = l.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+			return zapcore.NewTee(core, teeCore)
+		}))
+	}
+
+	var ring *ringBuffer
+
+	if cfg.ringSize > 0 {
+		ring = newRingBuffer(cfg.ringSize)
+		ringCore := zapcore.NewCore(newEncoder(cfg.encoder, zapConfig.EncoderConfig), ring, outputLevel)
+
+		l = l.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+			return zapcore.NewTee(core, ringCore)
+		}))
+	}
+
+	if cfg.journald != nil {
+		journalCore, closeJournal, err := newJournaldCore(*cfg.journald, outputLevel)
+		if err != nil {
+			closeSinks()
+			return nil, fmt.Errorf("could not create zap logger: %w", err)
+		}
+
+		l = l.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+			return zapcore.NewTee(core, journalCore)
+		}))
+
+		closeOutput := closeSinks
+		closeSinks = func() {
+			closeOutput()
+			closeJournal()
+		}
+	}
+
+	if cfg.eventLogSource != "" {
+		eventCore, closeEventLog, err := openEventLog(cfg.eventLogSource, zapConfig.EncoderConfig, outputLevel)
+		if err != nil {
+			closeSinks()
+			return nil, fmt.Errorf("could not create zap logger: %w", err)
+		}
+
+		l = l.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+			return zapcore.NewTee(core, eventCore)
+		}))
+
+		closeOutput := closeSinks
+		closeSinks = func() {
+			closeOutput()
+			closeEventLog()
+		}
+	}
+
+	// core wrappers are applied to the core of zap and have to be applied before
+	// the hooks, because the hooked core does not write entries itself
+	if cfg.metricsOnly {
+		l = l.WithOptions(zap.WrapCore(func(zapcore.Core) zapcore.Core {
+			return cfg.metricsCore(discardCore{LevelEnabler: outputLevel})
+		}))
+	} else {
+		l = l.WithOptions(zap.WrapCore(cfg.wrapCore))
+	}
+
+	for _, wrap := range cfg.coreWrappers {
+		l = l.WithOptions(zap.WrapCore(wrap))
+	}
+
+	if len(cfg.hooks) > 0 {
+		l = l.WithOptions(zap.Hooks(cfg.hooks...))
+	}
+
+	if cfg.noFatalExit {
+		l = l.WithOptions(zap.WithFatalHook(noExitHook{}))
+	}
+
+	if len(cfg.fields) > 0 {
+		l = l.With(cfg.fields...)
+	}
+
+	unfiltered := l
+	l = l.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return &levelCore{Core: core, level: atom}
+	}))
+
+	// stacktrace level changes are always applied to the base logger
+	base := l
+
+	// fix level for stack traces
+	if !cfg.disableStacktrace {
+		l = base.WithOptions(zap.AddStacktrace(stackTraceLevel))
+	}
+
+	if cfg.startupLog {
+		l.Info("logger configured", cfg.effectiveConfig(zapConfig, atom.Level())...)
+	}
+
+	if !cfg.disableInitialSync {
+		defer func() {
+			_ = l.Sync()
+		}()
+	}
+
+	logger := &Logger{
+		SugaredLogger:     l.Sugar(),
+		skipped:           skipCaller(l.Sugar()),
+		base:              base,
+		closeSinks:        closeSinks,
+		sinks:             sinks,
+		files:             cfg.files(),
+		teeFiles:          cfg.teeFiles(),
+		journaldSocket:    cfg.journaldSocket(),
+		memoryBuffer:      cfg.memoryBuffer,
+		disableCaller:     cfg.disableCaller,
+		ring:              ring,
+		atom:              atom,
+		unfiltered:        unfiltered,
+		auditAtom:         auditAtom,
+		contextFields:     cfg.contextFields,
+		requestIDKey:      cfg.requestIDKey,
+		events:            cfg.events,
+		currentLevel:      currentLevel,
+		disableStackTrace: cfg.disableStacktrace,
+	}
+
+	if cfg.global {
+		zap.ReplaceGlobals(l)
+	}
+
+	if cfg.levelFile != "" {
+		content, err := logger.readLevelFile(cfg.levelFile)
+		if err != nil {
+			closeSinks()
+			return nil, fmt.Errorf("could not create zap logger: %w", err)
+		}
+
+		stop, err := logger.watchLevelFile(cfg.levelFile, content)
+		if err != nil {
+			closeSinks()
+			return nil, fmt.Errorf("could not create zap logger: %w", err)
+		}
+
+		closeOutput := logger.closeSinks
+		logger.closeSinks = func() {
+			stop()
+			closeOutput()
+		}
+	}
+
+	if cfg.reopenOnSIGHUP {
+		stop := logger.reopenOnSIGHUP()
+		closeOutput := logger.closeSinks
+		logger.closeSinks = func() {
+			stop()
+			closeOutput()
+		}
+	}
+
+	if cfg.flushCtx != nil && cfg.flushInterval > 0 {
+		stop := flush(cfg.flushCtx, cfg.flushInterval, base)
+		closeOutput := logger.closeSinks
+		logger.closeSinks = func() {
+			stop()
+			closeOutput()
+		}
+	}
+
+	return logger, nil
+}
+
+// SetDebug enables or disables `DebugLevel`.
+func (l *Logger) SetDebug(d bool) {
+	level := zap.DebugLevel
+	stackTraceLevel := zap.ErrorLevel
+
+	l.m.Lock()
+	l.beforeDisable = nil
+	l.temporary = nil
+
+	if !d {
+		level = l.currentLevel
+		stackTraceLevel = zap.FatalLevel
+	}
+	l.m.Unlock()
+
+	l.atom.SetLevel(level)
+	l.stackTrace(stackTraceLevel)
+}
+
+// Disable disables (nearly) all output. Only `FatalLevel` errors are logged.
+func (l *Logger) Disable() {
+	l.m.Lock()
+	if l.beforeDisable == nil {
+		l.beforeDisable = &levels{
+			level:        l.atom.Level(),
+			currentLevel: l.currentLevel,
+		}
+	}
+	l.currentLevel = zapcore.FatalLevel
+	l.m.Unlock()
+	l.atom.SetLevel(zap.FatalLevel)
+}
+
+// Enable restores the level the logger had before Disable was called. It does nothing,
+// if the logger is not disabled or if the level was changed with SetLevel or SetDebug
+// after Disable.
+func (l *Logger) Enable() {
+	l.m.Lock()
+	before := l.beforeDisable
+	l.beforeDisable = nil
+
+	if before != nil {
+		l.currentLevel = before.currentLevel
+	}
+	l.m.Unlock()
+
+	if before == nil {
+		return
+	}
+
+	l.atom.SetLevel(before.level)
+
+	if before.level == zap.DebugLevel {
+		l.stackTrace(zap.ErrorLevel)
+		return
+	}
+
+	l.stackTrace(zap.FatalLevel)
+}
+
+// SetLevel sets the chosen level. If stacktraces are enabled, it adjusts stacktrace levels accordingly.
+func (l *Logger) SetLevel(level zapcore.Level) {
+	l.m.Lock()
+	oldLevel := l.currentLevel
+	l.currentLevel = level
+	l.beforeDisable = nil
+	l.temporary = nil
+	l.m.Unlock()
+	l.atom.SetLevel(level)
+
+	if level == zap.DebugLevel {
+		l.stackTrace(zap.ErrorLevel)
+		return
+	}
+
+	if oldLevel == zap.DebugLevel && level != zap.DebugLevel {
+		l.stackTrace(zap.FatalLevel)
+	}
+}
+
+// SetVerbosity sets the level by a numeric verbosity as used by `-v` flags: 0 is
+// `InfoLevel`, 1 and above is `DebugLevel`, -1 is `WarnLevel` and below is `ErrorLevel`.
+// Stacktrace levels are adjusted like with SetLevel.
+func (l *Logger) SetVerbosity(v int) {
+	l.SetLevel(verbosityLevel(v))
+}
+
+// verbosityLevel maps a numeric verbosity to a level.
+func verbosityLevel(v int) zapcore.Level {
+	switch {
+	case v > 0:
+		return zap.DebugLevel
+	case v == 0:
+		return zap.InfoLevel
+	case v == -1:
+		return zap.WarnLevel
+	default:
+		return zap.ErrorLevel
+	}
+}
+
+// WithTemporaryLevel sets the level and returns a function restoring the previous level.
+// The level is not scoped to the caller, it changes the level of the logger and all loggers
+// sharing its level until restore is called. Of several temporary levels, the most recent
+// one not restored yet applies. When all are restored, the level before the first one is
+// restored. SetLevel and SetDebug end all temporary levels, so that restore does nothing
+// afterwards.
+func (l *Logger) WithTemporaryLevel(level zapcore.Level) (restore func()) {
+	t := &temporaryLevel{level: level}
+
+	l.m.Lock()
+	if len(l.temporary) == 0 {
+		l.beforeTemporary = levels{
+			level:        l.atom.Level(),
+			currentLevel: l.currentLevel,
+		}
+	}
+
+	l.temporary = append(l.temporary, t)
+	l.atom.SetLevel(level)
+	l.m.Unlock()
+
+	l.stackTrace(stackTraceLevel(level))
+
+	var once sync.Once
+
+	return func() {
+		once.Do(func() {
+			l.restoreTemporaryLevel(t)
+		})
+	}
+}
+
+// temporaryLevel is a level set by WithTemporaryLevel.
+type temporaryLevel struct {
+	level zapcore.Level
+}
+
+// restoreTemporaryLevel ends the temporary level t and sets the most recent remaining
+// temporary level or, if there is none, the level before the first temporary level.
+func (l *Logger) restoreTemporaryLevel(t *temporaryLevel) {
+	l.m.Lock()
+
+	i := 0
+	for i < len(l.temporary) && l.temporary[i] != t {
+		i++
+	}
+
+	if i == len(l.temporary) {
+		l.m.Unlock()
+		return
+	}
+
+	l.temporary = append(l.temporary[:i], l.temporary[i+1:]...)
+
+	level := l.beforeTemporary.level
+	if n := len(l.temporary); n > 0 {
+		level = l.temporary[n-1].level
+	} else {
+		l.temporary = nil
+		l.currentLevel = l.beforeTemporary.currentLevel
+	}
+
+	l.atom.SetLevel(level)
+	l.m.Unlock()
+
+	l.stackTrace(stackTraceLevel(level))
+}
+
+// stackTraceLevel returns the stacktrace level for level: errors in debug mode, else only
+// fatal entries.
+func stackTraceLevel(level zapcore.Level) zapcore.Level {
+	if level == zap.DebugLevel {
+		return zap.ErrorLevel
+	}
+
+	return zap.FatalLevel
+}
+
+// AtomicLevel returns the level of the logger. The returned level is live, changing it
+// changes the level of the logger.
+func (l *Logger) AtomicLevel() zap.AtomicLevel {
+	return l.atom
+}
+
+// Get returns the embedded zap.Logger
+func (l *Logger) Get() *zap.SugaredLogger {
+	return l.SugaredLogger
+}
+
+func (l *Logger) stackTrace(lvl zapcore.Level) {
+	if l.disableStackTrace {
+		return
+	}
+
+	l.m.Lock()
+	l.SugaredLogger = l.base.WithOptions(zap.AddStacktrace(lvl)).Sugar()
+	l.skipped = skipCaller(l.SugaredLogger)
+	l.m.Unlock()
+}
+
+type config struct {
+	enableColor          bool
+	colorLevel           *zapcore.Level
+	levelColor           func(zapcore.Level) string
+	alignedConsole       bool
+	levelWidth           int
+	disableCaller        bool
+	callerLevel          *zapcore.Level
+	callerRoot           string
+	splitCaller          bool
+	packageKey           string
+	wrapperPackages      []string
+	prometheusMinLevel   *zapcore.Level
+	writeLatency         prometheus.Observer
+	fieldCount           prometheus.Observer
+	prometheusRegistry   prometheus.Registerer
+	prometheusName       string
+	events               *eventCounter
+	disableStacktrace    bool
+	disableTimestamps    bool
+	disableInitialSync   bool
+	metricsOnly          bool
+	jsonArray            bool
+	reopenOnSIGHUP       bool
+	levelFile            string
+	startupLog           bool
+	dpanicPanics         bool
+	noFatalExit          bool
+	global               bool
+	goroutineID          bool
+	stackDepthKey        string
+	structuredStacktrace bool
+	isDebug              bool
+	verbosity            *int
+	hooks                []func(zapcore.Entry) error
+	coreWrappers         []func(zapcore.Core) zapcore.Core
+	stacktraceExcludes   []func(zapcore.Entry, []zapcore.Field) bool
+	atom                 *zap.AtomicLevel
+	sinks                []string
+	fallbackSinks        []string
+	skipKeys             []string
+	allowKeys            []string
+	contextFields        []func(context.Context) []interface{}
+	requestIDKey         string
+	omitEmpty            bool
+	omitZero             bool
+	renameKeys           map[string]string
+	errorFieldsPrefix    *string
+	maskPatterns         []*regexp.Regexp
+	transforms           map[string][]func(zapcore.Field) zapcore.Field
+	errorKey             string
+	flattenSeparator     *string
+	severityKey          string
+	fields               []zapcore.Field
+	defaults             []zapcore.Field
+	deferredFields       []*deferredField
+	sampling             map[zapcore.Level]SampleRate
+	samplingHook         func(zapcore.Entry, bool)
+	throttle             *throttle
+	fileConfig           *FileConfig
+	teeFile              *FileConfig
+	fileTimeUTC          bool
+	utf16                bool
+	debugFile            *FileConfig
+	tee                  zapcore.Core
+	journald             *journaldConfig
+	eventLogSource       string
+	quarantine           *quarantineConfig
+	output               zapcore.WriteSyncer
+	constructionErr      zapcore.WriteSyncer
+	memoryBuffer         *memoryBuffer
+	ringSize             int
+	timeRounding         time.Duration
+	maxEncodeDepth       int
+	flushInterval        time.Duration
+	flushCtx             context.Context
+	keys                 Keys
+	timeEncoder          zapcore.TimeEncoder
+	fieldTimeEncoder     zapcore.TimeEncoder
+	durationEncoder      zapcore.DurationEncoder
+	reflectedEncoder     func(io.Writer) zapcore.ReflectedEncoder
+	encoder              EncoderType
+	encoderErr           error
+	fileErr              error
+}
+
+func (cfg FileConfig) sinkURI() string {
+	return fmt.Sprintf("%s://localhost/%s", lumberjackSinkURIPrefix, cfg.Path)
+}
+
+func pathFromURI(u *url.URL) string {
+	return strings.Replace(u.Path, "/", "", 1)
+}
+
+type lumberjackSink struct {
+	*lumberjack.Logger
+	utf16 *utf16Encoder
+	// syncer syncs the file after every write, if SyncEachLine is configured
+	syncer *fileSyncer
+}
+
+// Sync implements zap.Sink. The remaining methods are implemented
+// by the embedded *lumberjack.Logger.
+func (lumberjackSink) Sync() error { return nil }
+
+// Write writes p to the file. If SyncEachLine is configured, the file is synced
+// to disk after the write.
+func (s lumberjackSink) Write(p []byte) (int, error) {
+	if s.syncer == nil {
+		return s.write(p)
+	}
+
+	s.syncer.m.Lock()
+	defer s.syncer.m.Unlock()
+
+	n, err := s.write(p)
+	if err != nil {
+		return n, err
+	}
+
+	return n, s.syncer.sync(s.Filename)
+}
+
+func (s lumberjackSink) write(p []byte) (int, error) {
+	if s.utf16 != nil {
+		return s.utf16.write(s.Logger, p)
+	}
+
+	return s.Logger.Write(p)
+}
+
+// syncFile is the part of *os.File used by fileSyncer.
+type syncFile interface {
+	Stat() (os.FileInfo, error)
+	Sync() error
+	Close() error
+}
+
+// fileSyncer flushes a file written by lumberjack to disk. lumberjack does not expose its
+// file handle, but syncing any handle of a file flushes all of its written data, so the
+// syncer keeps its own handle and replaces it, after lumberjack rotated or reopened the
+// file. The writes of a file are serialized with the mutex, so that no other write can
+// rotate the file between a write and the sync.
+type fileSyncer struct {
+	m    sync.Mutex
+	open func(path string) (syncFile, error)
+	file syncFile
+}
+
+func newFileSyncer() *fileSyncer {
+	return &fileSyncer{
+		open: func(path string) (syncFile, error) {
+			return os.OpenFile(path, os.O_WRONLY, 0)
+		},
+	}
+}
+
+// sync syncs the file at path. The mutex must be held.
+func (s *fileSyncer) sync(path string) error {
+	if err := s.update(path); err != nil {
+		return err
+	}
+
+	return s.file.Sync()
+}
+
+// update opens the file at path, if it is not the file of the current handle.
+func (s *fileSyncer) update(path string) error {
+	if s.file != nil {
+		current, err := s.file.Stat()
+		if err != nil {
+			return err
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+
+		if os.SameFile(current, info) {
+			return nil
+		}
+
+		_ = s.file.Close()
+		s.file = nil
+	}
+
+	f, err := s.open(path)
+	if err != nil {
+		return err
+	}
+
+	s.file = f
+
+	return nil
+}
+
+// close closes the handle of the file.
+func (s *fileSyncer) close() error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+
+	err := s.file.Close()
+	s.file = nil
+
+	return err
+}
+
+// files returns the paths of the configured log files.
+func (c config) files() []string {
+	var files []string
+
+	for _, fc := range []*FileConfig{c.fileConfig, c.teeFile, c.debugFile} {
+		if fc != nil {
+			files = append(files, fc.Path)
+		}
+	}
+
+	return files
+}
+
+// teeFiles returns the paths of the log files written in addition to the sinks.
+func (c config) teeFiles() []string {
+	var files []string
+
+	for _, fc := range []*FileConfig{c.teeFile, c.debugFile} {
+		if fc != nil {
+			files = append(files, fc.Path)
+		}
+	}
+
+	return files
+}
+
+func (c config) registerFileSink() error {
+	return fileSinks.register(*c.fileConfig)
+}
+
+// nolint: gochecknoglobals
+var fileSinks = &fileSinkRegistry{
+	configs: map[string]FileConfig{},
+	sinks:   map[string]lumberjackSink{},
+	refs:    map[string]int{},
+}
+
+// fileSinkRegistry shares one lumberjack logger per file between all flash loggers
+// in the process, so that writes and rotations of the same file are synchronized.
+// Registering a path again with a different FileConfig fails, as long as the file is
+// open. The file is closed and its configuration dropped, when the last sink of the
+// file is closed.
+type fileSinkRegistry struct {
+	once    sync.Once
+	err     error
+	m       sync.Mutex
+	configs map[string]FileConfig
+	sinks   map[string]lumberjackSink
+	refs    map[string]int
+}
+
+func (r *fileSinkRegistry) register(cfg FileConfig) error {
+	r.once.Do(func() {
+		r.err = zap.RegisterSink(lumberjackSinkURIPrefix, r.open)
+	})
+
+	if r.err != nil {
+		return r.err
+	}
+
+	key := fileSinkKey(cfg.Path)
+
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	// the file of a JSON array is truncated and must not be shared
+	if cfg.jsonArray {
+		if r.refs[key] > 0 {
+			return fmt.Errorf("file %s is already in use", cfg.Path)
+		}
+
+		if err := os.Truncate(cfg.Path, 0); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	existing, ok := r.configs[key]
+	if !ok || r.refs[key] == 0 {
+		r.configs[key] = cfg
+		return nil
+	}
+
+	existing.Path = cfg.Path
+	if existing != cfg {
+		return fmt.Errorf("file %s is already used with a different configuration", cfg.Path)
+	}
+
+	return nil
+}
+
+func (r *fileSinkRegistry) open(u *url.URL) (zap.Sink, error) {
+	path := pathFromURI(u)
+	key := fileSinkKey(path)
+
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	s, ok := r.sinks[key]
+	if !ok {
+		cfg := r.configs[key]
+
+		// lumberjack rotates files of the default size, if no MaxSize is configured
+		maxSize := cfg.MaxSize
+		if cfg.jsonArray {
+			maxSize = math.MaxInt32
+		}
+
+		s = lumberjackSink{
+			Logger: &lumberjack.Logger{
+				Filename:   path,
+				MaxSize:    maxSize,
+				MaxAge:     cfg.MaxAge,
+				MaxBackups: cfg.MaxBackups,
+				Compress:   cfg.Compress || cfg.CompressExisting,
+			},
+		}
+
+		if cfg.SyncEachLine {
+			s.syncer = newFileSyncer()
+		}
+
+		if cfg.utf16 {
+			s.utf16 = &utf16Encoder{}
+		}
+
+		// opening the file starts the compression of existing backups
+		if cfg.CompressExisting {
+			if _, err := s.Write(nil); err != nil {
+				return nil, err
+			}
+		}
+
+		r.sinks[key] = s
+	}
+
+	r.refs[key]++
+
+	ref := &fileSinkRef{lumberjackSink: s}
+	ref.close = func() error {
+		return r.release(key)
+	}
+
+	return ref, nil
+}
+
+// release closes the file, if it is not used by any other sink.
+func (r *fileSinkRegistry) release(key string) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	r.refs[key]--
+	if r.refs[key] > 0 {
+		return nil
+	}
+
+	s := r.sinks[key]
+
+	delete(r.sinks, key)
+	delete(r.configs, key)
+	delete(r.refs, key)
+
+	if s.syncer != nil {
+		_ = s.syncer.close()
+	}
+
+	return s.Logger.Close()
+}
+
+// fileSinkRef is a sink of a shared file. Closing it releases the file.
+type fileSinkRef struct {
+	lumberjackSink
+	once  sync.Once
+	close func() error
+}
+
+// Close releases the file once.
+func (s *fileSinkRef) Close() error {
+	var err error
+
+	s.once.Do(func() {
+		err = s.close()
+	})
+
+	return err
+}
+
+// fileSinkKey resolves the path to identify the file independent of the working directory.
+func fileSinkKey(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+
+	return abs
+}
+
+// effectiveConfig returns the configuration as fields for the startup log.
+func (c config) effectiveConfig(zapConfig zap.Config, level zapcore.Level) []zap.Field {
+	sampledLevels := make([]string, 0, len(c.sampling))
+	for lvl := range c.sampling {
+		sampledLevels = append(sampledLevels, lvl.String())
+	}
+
+	sort.Strings(sampledLevels)
+
+	return []zap.Field{
+		zap.Stringer("min_level", level),
+		zap.Stringer("encoder", c.encoder),
+		zap.Strings("sinks", zapConfig.OutputPaths),
+		zap.Strings("sampled_levels", sampledLevels),
+		zap.Bool("stacktrace", !c.disableStacktrace),
+		zap.Bool("caller", !c.disableCaller),
+	}
+}
+
+// validate checks the configuration for errors, which would otherwise only surface
+// when logging.
+func (c config) validate() error {
+	if c.encoderErr != nil {
+		return c.encoderErr
+	}
+
+	if c.fileErr != nil {
+		return c.fileErr
+	}
+
+	for _, fc := range []*FileConfig{c.fileConfig, c.teeFile, c.debugFile} {
+		if fc != nil && fc.Path == "" {
+			return errors.New("invalid file config: path must not be empty")
+		}
+	}
+
+	if c.jsonArray {
+		return c.validateJSONArray()
+	}
+
+	return nil
+}
+
+// validateJSONArray checks the configuration of WithJSONArray.
+func (c config) validateJSONArray() error {
+	switch {
+	case c.encoder != JSON:
+		return errors.New("invalid json array config: encoder must be json")
+	case c.fileConfig == nil:
+		return errors.New("invalid json array config: no file configured")
+	case c.fileConfig.MaxSize != 0:
+		return errors.New("invalid json array config: file must not be rotated")
+	}
+
+	return nil
+}
+
+// wrapCore applies all configured core wrappers.
+func (c config) wrapCore(core zapcore.Core) zapcore.Core {
+	if c.structuredStacktrace && c.encoder == JSON {
+		key := c.keys.Stacktrace
+		if key == "" {
+			key = "stacktrace"
+		}
+
+		core = &transformCore{Core: core, t: structuredStack(key)}
+	}
+
+	if c.splitCaller && c.encoder == JSON && !c.disableCaller {
+		core = &transformCore{Core: core, t: splitCaller(callerFile(c.callerRoot))}
+	}
+
+	if c.severityKey != "" {
+		core = &transformCore{Core: core, t: severityField(c.severityKey)}
+	}
+
+	if c.callerLevel != nil && !c.disableCaller {
+		core = &transformCore{Core: core, t: callerLevel(*c.callerLevel)}
+	}
+
+	if c.goroutineID {
+		core = &transformCore{Core: core, t: goroutineField{}}
+	}
+
+	if c.stackDepthKey != "" {
+		core = &transformCore{Core: core, t: stackDepthField(c.stackDepthKey)}
+	}
+
+	if c.packageKey != "" && !c.disableCaller {
+		core = &transformCore{Core: core, t: packageField(c.packageKey)}
+	}
+
+	if c.flattenSeparator != nil && c.encoder == JSON {
+		core = &transformCore{Core: core, t: flattenFields(*c.flattenSeparator)}
+	}
+
+	if len(c.renameKeys) > 0 {
+		core = &transformCore{Core: core, t: mapFields(renameKeys(c.renameKeys))}
+	}
+
+	if len(c.skipKeys) > 0 {
+		core = &transformCore{Core: core, t: newSkipFields(c.skipKeys)}
+	}
+
+	if len(c.allowKeys) > 0 {
+		core = &transformCore{Core: core, t: mapFields(allowKeys(c.allowKeys))}
+	}
+
+	if c.omitEmpty {
+		core = &transformCore{Core: core, t: mapFields(omitEmpty(c.omitZero))}
+	}
+
+	if len(c.transforms) > 0 {
+		core = &transformCore{Core: core, t: mapFields(transformFields(c.transforms))}
+	}
+
+	if c.errorKey != "" {
+		core = &transformCore{Core: core, t: mapFields(renameErrorKey(c.errorKey))}
+	}
+
+	if c.fieldTimeEncoder != nil {
+		core = &transformCore{Core: core, t: mapFields(encodeTimeFields(c.fieldTimeEncoder))}
+	}
+
+	if len(c.deferredFields) > 0 {
+		core = &transformCore{Core: core, t: deferredFields(c.deferredFields)}
+	}
+
+	if len(c.maskPatterns) > 0 {
+		core = &transformCore{Core: core, t: masker(c.maskPatterns)}
+	}
+
+	if len(c.stacktraceExcludes) > 0 {
+		core = &transformCore{Core: core, t: &stackExclude{excludes: c.stacktraceExcludes}}
+	}
+
+	if c.errorFieldsPrefix != nil {
+		core = &transformCore{Core: core, t: errorFields(*c.errorFieldsPrefix)}
+	}
+
+	if len(c.defaults) > 0 {
+		core = &transformCore{Core: core, t: defaultFields(c.defaults)}
+	}
+
+	if len(c.wrapperPackages) > 0 && !c.disableCaller {
+		core = &transformCore{Core: core, t: wrapperCaller(c.wrapperPackages)}
+	}
+
+	core = &transformCore{Core: core, t: &lazyResolver{level: core}}
+
+	// the metrics are observed inside of the sampling, which does not write entries
+	if c.writeLatency != nil {
+		core = &latencyCore{Core: core, observer: c.writeLatency}
+	}
+
+	core = c.metricsCore(core)
+
+	if len(c.sampling) > 0 {
+		core = newSamplingCore(core, c.sampling, c.samplingHook)
+	}
+
+	if c.throttle != nil {
+		core = &throttleCore{Core: core, throttle: c.throttle}
+	}
+
+	return core
+}
+
+// metricsCore applies the core wrappers counting the fields of the entries, which are
+// also applied WithMetricsOnly.
+func (c config) metricsCore(core zapcore.Core) zapcore.Core {
+	if c.fieldCount != nil {
+		core = &transformCore{Core: core, t: &fieldCounter{observer: c.fieldCount, minLevel: c.prometheusMinLevel}}
+	}
+
+	return core
+}
+
+// build creates the logger with the error output errSink like zap.Config.Build, but
+// additionally returns a function to close the opened sinks.
+func build(cfg config, zapConfig zap.Config, level zapcore.LevelEnabler, errSink zapcore.WriteSyncer) (*zap.Logger, func(), error) {
+	paths, duplicates := uniqueSinks(zapConfig.OutputPaths)
+
+	sink, closeSinks, err := cfg.openSinks(paths, errSink)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(duplicates) > 0 {
+		cfg.diagnose(errSink, "ignoring duplicate sinks: %s", strings.Join(duplicates, ", "))
+	}
+
+	opts := []zap.Option{zap.ErrorOutput(errSink)}
+
+	if zapConfig.Development {
+		opts = append(opts, zap.Development())
+	}
+
+	if !zapConfig.DisableCaller {
+		opts = append(opts, zap.AddCaller())
+	}
+
+	if !zapConfig.DisableStacktrace {
+		opts = append(opts, zap.AddStacktrace(zap.ErrorLevel))
+	}
+
+	core := zapcore.NewCore(newEncoder(cfg.encoder, zapConfig.EncoderConfig), sink, level)
+
+	return zap.New(core, opts...), closeSinks, nil
+}
+
+// diagnose writes a diagnostic message about creating the logger to the writer of
+// WithConstructionErrorWriter or, if not configured, to errSink.
+func (c config) diagnose(errSink zapcore.WriteSyncer, format string, args ...interface{}) {
+	w := errSink
+	if c.constructionErr != nil {
+		w = c.constructionErr
+	}
+
+	fmt.Fprintf(w, "%v flash: %s\n", time.Now(), fmt.Sprintf(format, args...))
+	_ = w.Sync()
+}
+
+// openSinks opens the sinks with the given paths. It returns a function to close them.
+func (c config) openSinks(paths []string, errSink zapcore.WriteSyncer) (zapcore.WriteSyncer, func(), error) {
+	sink, closeSinks, err := c.openOutput(paths, errSink)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// the fallback sinks are not written as JSON array
+	if c.jsonArray && len(paths) == 1 && paths[0] == c.fileConfig.sinkURI() {
+		sink, closeSinks = newJSONArraySink(sink, closeSinks)
+	}
+
+	if c.memoryBuffer == nil {
+		return sink, closeSinks, nil
+	}
+
+	c.memoryBuffer.m.Lock()
+	c.memoryBuffer.sink = sink
+	c.memoryBuffer.m.Unlock()
+
+	return c.memoryBuffer, closeSinks, nil
+}
+
+// openOutput opens the sinks without buffering.
+func (c config) openOutput(paths []string, errSink zapcore.WriteSyncer) (zapcore.WriteSyncer, func(), error) {
+	switch {
+	case c.output != nil:
+		return c.output, func() {}, nil
+	case c.quarantine != nil:
+		return openQuarantinedSinks(*c.quarantine, paths, errSink)
+	default:
+		return zap.Open(paths...)
+	}
+}
+
+// uniqueSinks removes duplicate sinks, which would write each entry multiple times. The
+// standard streams are normalized, so that for example `stderr` and `stderr://` are the
+// same sink. It returns the removed duplicates.
+func uniqueSinks(sinks []string) (unique, duplicates []string) {
+	seen := make(map[string]struct{}, len(sinks))
+
+	for _, s := range sinks {
+		if s == "stdout://" || s == "stderr://" {
+			s = strings.TrimSuffix(s, "://")
+		}
+
+		if _, ok := seen[s]; ok {
+			duplicates = append(duplicates, s)
+			continue
+		}
+
+		seen[s] = struct{}{}
+		unique = append(unique, s)
+	}
+
+	return unique, duplicates
+}
+
+// newEncoder creates the encoder of type e.
+func newEncoder(e EncoderType, cfg zapcore.EncoderConfig) zapcore.Encoder {
+	switch e {
+	case JSON:
+		return zapcore.NewJSONEncoder(cfg)
+	case LogFmt:
+		return zaplogfmt.NewEncoder(cfg)
+	default:
+		return zapcore.NewConsoleEncoder(cfg)
+	}
+}
+
+// fileCallerEncoder returns the caller encoder for JSON output, which formats the caller
+// relative to the root of WithCallerModuleRelative, if configured, and without padding.
+func fileCallerEncoder(cfg config) zapcore.CallerEncoder {
+	if cfg.callerRoot == "" {
+		return zapcore.ShortCallerEncoder
+	}
+
+	path := moduleRelativePath(cfg.callerRoot)
+
+	return func(c zapcore.EntryCaller, enc zapcore.PrimitiveArrayEncoder) {
+		enc.AppendString(path(c))
+	}
+}
+
+func genZapConfig(cfg config) (zap.Config, error) {
+	zapConfig := zap.NewProductionConfig()
+	zapConfig.DisableStacktrace = cfg.disableStacktrace
+	zapConfig.Sampling = nil
+	zapConfig.DisableCaller = cfg.disableCaller
+	zapConfig.Development = cfg.dpanicPanics
+	zapConfig.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+
+	if cfg.timeEncoder != nil {
+		zapConfig.EncoderConfig.EncodeTime = cfg.timeEncoder
+	}
+
+	if cfg.reflectedEncoder != nil {
+		zapConfig.EncoderConfig.NewReflectedEncoder = cfg.reflectedEncoder
+	}
+
+	if cfg.maxEncodeDepth > 0 {
+		zapConfig.EncoderConfig.NewReflectedEncoder = depthLimitedEncoder(cfg.maxEncodeDepth, cfg.reflectedEncoder)
+	}
+
+	if cfg.timeRounding > 0 {
+		zapConfig.EncoderConfig.EncodeTime = roundingTimeEncoder(cfg.timeRounding, zapConfig.EncoderConfig.EncodeTime)
+	}
+
+	if cfg.fileTimeUTC && cfg.fileConfig != nil {
+		zapConfig.EncoderConfig.EncodeTime = utcTimeEncoder(zapConfig.EncoderConfig.EncodeTime)
+	}
+
+	zapConfig.EncoderConfig.EncodeDuration = zapcore.StringDurationEncoder
+
+	if cfg.durationEncoder != nil {
+		zapConfig.EncoderConfig.EncodeDuration = cfg.durationEncoder
+	}
+	zapConfig.EncoderConfig.EncodeLevel = zapcore.CapitalLevelEncoder
+
+	zapConfig.Encoding = cfg.encoder.String()
+
+	// no colors when logging to file
+	color := cfg.enableColor && cfg.fileConfig == nil
+	colored := func(_ zapcore.Level, s string) string { return s }
+
+	if color {
+		colored = colorize
+		zapConfig.EncoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
+	}
+
+	if color && cfg.levelColor != nil {
+		colored = customColorize(cfg.levelColor)
+		zapConfig.EncoderConfig.EncodeLevel = paddedLevelEncoder(0, colored)
+	}
+
+	if color && cfg.colorLevel != nil {
+		minLevel, colorizeLevel := *cfg.colorLevel, colored
+		colored = func(l zapcore.Level, s string) string {
+			if l < minLevel {
+				return s
+			}
+
+			return colorizeLevel(l, s)
+		}
+		zapConfig.EncoderConfig.EncodeLevel = paddedLevelEncoder(0, colored)
+	}
+
+	if cfg.levelWidth > 0 && cfg.encoder == Console {
+		zapConfig.EncoderConfig.EncodeLevel = paddedLevelEncoder(cfg.levelWidth, colored)
+	}
+
+	callerPath := zapcore.EntryCaller.TrimmedPath
+
+	if cfg.callerRoot != "" {
+		callerPath = moduleRelativePath(cfg.callerRoot)
+		zapConfig.EncoderConfig.EncodeCaller = fileCallerEncoder(cfg)
+	}
+
+	if cfg.alignedConsole && cfg.encoder == Console {
+		zapConfig.EncoderConfig.EncodeLevel = paddedLevelEncoder(alignedLevelWidth, colored)
+		zapConfig.EncoderConfig.EncodeCaller = paddedCallerEncoder(alignedCallerWidth, callerPath)
+	}
+
+	if len(cfg.sinks) > 0 {
+		zapConfig.OutputPaths = cfg.sinks
+	}
+
+	cfg.keys.apply(&zapConfig.EncoderConfig)
 
 	if cfg.fileConfig != nil {
 		if err := cfg.registerFileSink(); err != nil {
-			panic(err)
+			return zapConfig, err
 		}
 
 		zapConfig.OutputPaths = []string{cfg.fileConfig.sinkURI()}
 	}
 
+	// WithoutTimestamps always takes precedence over a configured time key
 	if cfg.disableTimestamps {
 		zapConfig.EncoderConfig.TimeKey = ""
 	}
 
-	return zapConfig
+	return zapConfig, nil
 }
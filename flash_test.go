@@ -3,13 +3,24 @@ package flash_test
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"net/http"
 	"net/url"
 	"os"
+	"regexp"
+	"runtime"
 	"strings"
+	"sync"
 	"testing"
+	"time"
+	"unicode/utf16"
 
 	"github.com/postfinance/flash"
 	"github.com/prometheus/client_golang/prometheus"
@@ -58,7 +69,7 @@ func TestDefault(t *testing.T) {
 		sink.Reset()
 		l := flash.New(flash.WithSinks("memory://"), flash.WithEncoder(flash.Console))
 		l.Info("a log message")
-		want := fmt.Sprintf("%s\t%s\t%s", "INFO", "flash/flash_test.go:60", "a log message")
+		want := fmt.Sprintf("%s\t%s\t%s", "INFO", "flash/flash_test.go:71", "a log message")
 		entry := sink.String()
 		assert.True(t, strings.Contains(entry, want), "got: %s, want:%s", entry, want)
 	})
@@ -76,7 +87,7 @@ func TestDefault(t *testing.T) {
 		sink.Reset()
 		l := flash.New(flash.WithSinks("memory://"), flash.WithEncoder(flash.Console), flash.WithoutTimestamps())
 		l.Info("a log message")
-		assert.Equal(t, "INFO\tflash/flash_test.go:78\ta log message\n", sink.String())
+		assert.Equal(t, "INFO\tflash/flash_test.go:89\ta log message\n", sink.String())
 	})
 }
 
@@ -97,7 +108,7 @@ func TestLogFmt(t *testing.T) {
 	l := flash.New(flash.WithSinks("memory://"), flash.WithEncoder(flash.LogFmt), flash.WithoutTimestamps())
 	l.Info("info")
 	require.NotEmpty(t, sink.String())
-	assert.Equal(t, "level=INFO caller=flash/flash_test.go:98 msg=info\n", sink.String())
+	assert.Equal(t, "level=INFO caller=flash/flash_test.go:109 msg=info\n", sink.String())
 }
 
 func TestWithStacktraceWithDebug(t *testing.T) {
@@ -238,13 +249,13 @@ func TestWithPrometheus(t *testing.T) {
 	l.Debug("debug")
 
 	const metadata = `
-		# HELP appname_log_messages_total How many log messages created, partitioned by log level.
+		# HELP appname_log_messages_total How many log messages created, partitioned by log level and component.
         # TYPE appname_log_messages_total counter
 	`
 
 	expected := `
-		appname_log_messages_total{level="error"} 1
-		appname_log_messages_total{level="info"} 2
+		appname_log_messages_total{component="",level="error"} 1
+		appname_log_messages_total{component="",level="info"} 2
 	`
 
 	err := testutil.GatherAndCompare(r, strings.NewReader(metadata+expected), "appname_log_messages_total")
@@ -253,9 +264,9 @@ func TestWithPrometheus(t *testing.T) {
 	l.Debug("debug")
 
 	expected = `
-		appname_log_messages_total{level="debug"} 1
-		appname_log_messages_total{level="error"} 1
-		appname_log_messages_total{level="info"} 2
+		appname_log_messages_total{component="",level="debug"} 1
+		appname_log_messages_total{component="",level="error"} 1
+		appname_log_messages_total{component="",level="info"} 2
 	`
 	err = testutil.GatherAndCompare(r, strings.NewReader(metadata+expected), "appname_log_messages_total")
 	require.NoError(t, err, "unexpected collecting result")
@@ -276,7 +287,7 @@ func TestWithFileConfig(t *testing.T) {
 
 	l.Info("hello world")
 
-	d, err := os.ReadFile(file.Name())
+	d, err := ioutil.ReadFile(file.Name())
 	require.NoError(t, err)
 	assert.Contains(t, string(d), "INFO")
 }
@@ -314,3 +325,2459 @@ type logEntry struct {
 	Msg        string `json:"msg"`
 	Stacktrace string `json:"stacktrace"`
 }
+
+func TestWithSkipKeys(t *testing.T) {
+	defer sink.Reset()
+
+	l := flash.New(flash.WithSinks("memory://"), flash.WithSkipKeys("secret", "token"))
+	l.With("token", "abc").Infow("info", "secret", "xyz", "user", "john")
+
+	assert.NotContains(t, sink.String(), "secret")
+	assert.NotContains(t, sink.String(), "token")
+	assert.Contains(t, sink.String(), `"user":"john"`)
+
+	t.Run("concurrent encoding", func(t *testing.T) {
+		sink.Reset()
+
+		done := make(chan struct{})
+
+		for i := 0; i < 10; i++ {
+			go func(i int) {
+				defer func() { done <- struct{}{} }()
+
+				for j := 0; j < 100; j++ {
+					l.Infow("info", "secret", "xyz", "goroutine", i, "n", j)
+				}
+			}(i)
+		}
+
+		for i := 0; i < 10; i++ {
+			<-done
+		}
+
+		e, err := sink.parse()
+		require.NoError(t, err)
+		assert.Len(t, e, 1000)
+		assert.NotContains(t, sink.String(), "secret")
+	})
+
+	t.Run("with prometheus", func(t *testing.T) {
+		sink.Reset()
+
+		l := flash.New(flash.WithSinks("memory://"), flash.WithSkipKeys("secret"), flash.WithPrometheus("skip", prometheus.NewRegistry()))
+		l.Infow("info", "secret", "xyz", "user", "john")
+
+		assert.Contains(t, sink.String(), `"user":"john"`)
+		assert.NotContains(t, sink.String(), "secret")
+	})
+}
+
+func TestWithAnnotations(t *testing.T) {
+	defer sink.Reset()
+
+	require.NoError(t, os.Setenv("FLASH_POD_NAME", "pod-1"))
+	require.NoError(t, os.Setenv("FLASH_NAMESPACE", "default"))
+
+	defer func() {
+		_ = os.Unsetenv("FLASH_POD_NAME")
+		_ = os.Unsetenv("FLASH_NAMESPACE")
+	}()
+
+	l := flash.New(flash.WithSinks("memory://"), flash.WithAnnotations(map[string]string{
+		"pod":       "${FLASH_POD_NAME}",
+		"namespace": "ns-${FLASH_NAMESPACE}",
+		"node":      "${FLASH_NODE_NAME}",
+	}))
+	l.Info("info")
+
+	entry := sink.String()
+	assert.Contains(t, entry, `"pod":"pod-1"`)
+	assert.Contains(t, entry, `"namespace":"ns-default"`)
+	assert.NotContains(t, entry, `"node"`)
+}
+
+func TestWithFallbackSink(t *testing.T) {
+	defer sink.Reset()
+
+	dir, err := ioutil.TempDir("", "flash")
+	require.NoError(t, err)
+
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	unwritable := dir + "/missing/dir/test.log"
+
+	var diagnostics bytes.Buffer
+
+	l := flash.New(flash.WithSinks(unwritable), flash.WithFallbackSink("memory://"), flash.WithConstructionErrorWriter(&diagnostics))
+	l.Info("info")
+
+	e, err := sink.parse()
+	require.NoError(t, err)
+	require.Len(t, e, 1)
+	assert.Equal(t, "info", e[0].Msg)
+
+	assert.Equal(t, 1, strings.Count(diagnostics.String(), "\n"))
+	assert.Contains(t, diagnostics.String(), "could not open sinks "+unwritable+", using fallback sinks memory://")
+
+	assert.Panics(t, func() {
+		flash.New(flash.WithSinks(unwritable))
+	})
+}
+
+func TestStdPrinter(t *testing.T) {
+	defer sink.Reset()
+
+	l := flash.New(flash.WithSinks("memory://"))
+
+	consumer := func(p flash.Printer) {
+		p.Printf("connected to %s:%d", "localhost", 8080)
+		p.Println("connection", "closed")
+	}
+
+	consumer(l.StdPrinter(zapcore.WarnLevel))
+
+	e, err := sink.parse()
+	require.NoError(t, err)
+	require.Len(t, e, 2)
+	assert.Equal(t, "WARN", e[0].Level)
+	assert.Equal(t, "connected to localhost:8080", e[0].Msg)
+	assert.Contains(t, e[0].Caller, "flash_test.go")
+	assert.Equal(t, "connection closed", e[1].Msg)
+
+	sink.Reset()
+	l.StdPrinter(zapcore.DebugLevel).Printf("debug")
+	assert.Empty(t, sink.String())
+
+	t.Run("stacktrace level changed", func(t *testing.T) {
+		sink.Reset()
+
+		l := flash.New(flash.WithSinks("memory://"), flash.WithStacktrace())
+		p := l.StdPrinter(zapcore.ErrorLevel)
+		p.Printf("without stacktrace")
+
+		l.SetDebug(true)
+		p.Printf("with stacktrace")
+
+		e, err := sink.parse()
+		require.NoError(t, err)
+		require.Len(t, e, 2)
+		assert.Empty(t, e[0].Stacktrace)
+		assert.NotEmpty(t, e[1].Stacktrace)
+		assert.Contains(t, e[1].Caller, "flash_test.go")
+	})
+}
+
+func TestWithAtomicLevel(t *testing.T) {
+	defer sink.Reset()
+
+	atom := zap.NewAtomicLevelAt(zapcore.InfoLevel)
+
+	l1 := flash.New(flash.WithSinks("memory://"), flash.WithAtomicLevel(atom))
+	l2 := flash.New(flash.WithSinks("memory://"), flash.WithAtomicLevel(atom))
+
+	assert.Equal(t, atom, l1.AtomicLevel())
+
+	l1.Debug("debug")
+	l2.Debug("debug")
+	assert.Empty(t, sink.String())
+
+	atom.SetLevel(zapcore.DebugLevel)
+
+	l1.Debug("debug")
+	l2.Debug("debug")
+
+	e, err := sink.parse()
+	require.NoError(t, err)
+	assert.Len(t, e, 2)
+
+	t.Run("debug level with stacktrace", func(t *testing.T) {
+		sink.Reset()
+
+		atom := zap.NewAtomicLevelAt(zapcore.DebugLevel)
+		l := flash.New(flash.WithSinks("memory://"), flash.WithAtomicLevel(atom), flash.WithStacktrace())
+
+		l.Error("error")
+
+		e, err := sink.parse()
+		require.NoError(t, err)
+		require.Len(t, e, 1)
+		// like in debug mode, errors have stacktraces
+		assert.NotEmpty(t, e[0].Stacktrace, "no stack trace logged")
+	})
+}
+
+func TestLazyAt(t *testing.T) {
+	defer sink.Reset()
+
+	calls := 0
+	fn := func() []zap.Field {
+		calls++
+		return []zap.Field{zap.String("diagnostic", "verbose")}
+	}
+
+	l := flash.New(flash.WithSinks("memory://"))
+	l.Infow("info", flash.LazyAt(zapcore.DebugLevel, fn))
+
+	assert.Equal(t, 0, calls)
+	assert.NotContains(t, sink.String(), "diagnostic")
+
+	sink.Reset()
+	l.SetDebug(true)
+	l.Infow("info", flash.LazyAt(zapcore.DebugLevel, fn))
+
+	assert.Equal(t, 1, calls)
+	assert.Contains(t, sink.String(), `"diagnostic":"verbose"`)
+
+	t.Run("entry level below lazy level", func(t *testing.T) {
+		sink.Reset()
+		l.Infow("info", flash.LazyAt(zapcore.ErrorLevel, fn))
+		assert.Equal(t, 1, calls)
+		assert.NotContains(t, sink.String(), "diagnostic")
+	})
+
+	t.Run("with context", func(t *testing.T) {
+		sink.Reset()
+		l.With(flash.LazyAt(zapcore.DebugLevel, fn)).Info("info")
+		assert.Equal(t, 2, calls)
+		assert.Contains(t, sink.String(), `"diagnostic":"verbose"`)
+	})
+}
+
+func TestSetLevelToggleWithStacktrace(t *testing.T) {
+	defer sink.Reset()
+
+	r := prometheus.NewRegistry()
+	l := flash.New(flash.WithSinks("memory://"), flash.WithStacktrace(), flash.WithPrometheus("toggle", r))
+
+	for i := 0; i < 100; i++ {
+		l.SetLevel(zapcore.DebugLevel)
+		l.SetLevel(zapcore.InfoLevel)
+	}
+
+	l.SetLevel(zapcore.DebugLevel)
+	l.Error("error")
+
+	e, err := sink.parse()
+	require.NoError(t, err)
+	require.Len(t, e, 1)
+	assert.NotEmpty(t, e[0].Stacktrace)
+	assert.Equal(t, 1, strings.Count(e[0].Stacktrace, "TestSetLevelToggleWithStacktrace"))
+
+	const expected = `
+		# HELP toggle_log_messages_total How many log messages created, partitioned by log level and component.
+		# TYPE toggle_log_messages_total counter
+		toggle_log_messages_total{component="",level="error"} 1
+	`
+
+	err = testutil.GatherAndCompare(r, strings.NewReader(expected), "toggle_log_messages_total")
+	require.NoError(t, err, "hooks should not be compounded")
+}
+
+func TestWithKeys(t *testing.T) {
+	defer sink.Reset()
+
+	l := flash.New(flash.WithSinks("memory://"), flash.WithKeys(flash.Keys{Time: "@timestamp", Message: "message"}))
+	l.Info("info")
+	assert.Contains(t, sink.String(), `"@timestamp":`)
+	assert.Contains(t, sink.String(), `"message":"info"`)
+	assert.NotContains(t, sink.String(), `"ts":`)
+
+	t.Run("WithoutTimestamps takes precedence regardless of option order", func(t *testing.T) {
+		for _, opts := range [][]flash.Option{
+			{flash.WithKeys(flash.Keys{Time: "@timestamp"}), flash.WithoutTimestamps()},
+			{flash.WithoutTimestamps(), flash.WithKeys(flash.Keys{Time: "@timestamp"})},
+		} {
+			sink.Reset()
+
+			l := flash.New(append(opts, flash.WithSinks("memory://"))...)
+			l.Info("info")
+			assert.NotContains(t, sink.String(), `"@timestamp":`)
+			assert.NotContains(t, sink.String(), `"ts":`)
+		}
+	})
+}
+
+func TestWithAlert(t *testing.T) {
+	defer sink.Reset()
+
+	alerts := make(chan zapcore.Entry, 10)
+
+	l := flash.New(flash.WithSinks("memory://"), flash.WithAlert(zapcore.ErrorLevel, func(e zapcore.Entry) {
+		alerts <- e
+	}))
+
+	l.Info("info")
+	l.Error("error")
+
+	select {
+	case e := <-alerts:
+		assert.Equal(t, "error", e.Message)
+	case <-time.After(time.Second):
+		t.Fatal("no alert received")
+	}
+
+	select {
+	case e := <-alerts:
+		t.Fatalf("unexpected alert for %s entry", e.Level)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	t.Run("panic in notify is recovered", func(t *testing.T) {
+		l := flash.New(flash.WithSinks("memory://"), flash.WithAlert(zapcore.ErrorLevel, func(zapcore.Entry) {
+			defer func() { alerts <- zapcore.Entry{} }()
+			panic("notify")
+		}))
+
+		l.Error("error")
+		<-alerts
+	})
+
+	t.Run("blocking notify drops entries", func(t *testing.T) {
+		release := make(chan struct{})
+		called := make(chan zapcore.Level, 1000)
+
+		l := flash.New(flash.WithSinks("memory://"), flash.WithAlert(zapcore.ErrorLevel, func(e zapcore.Entry) {
+			called <- e.Level
+			if e.Level < zapcore.DPanicLevel {
+				<-release
+			}
+		}))
+
+		done := make(chan struct{})
+
+		go func() {
+			for i := 0; i < 500; i++ {
+				l.Error("error")
+			}
+			l.DPanic("dpanic")
+			close(done)
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("logging blocked by notify")
+		}
+
+		close(release)
+
+		levels := map[zapcore.Level]int{}
+
+		for {
+			select {
+			case lvl := <-called:
+				levels[lvl]++
+				continue
+			case <-time.After(100 * time.Millisecond):
+			}
+
+			break
+		}
+
+		assert.Equal(t, 1, levels[zapcore.DPanicLevel])
+		assert.True(t, levels[zapcore.ErrorLevel] < 500)
+	})
+}
+
+func TestWithFileSharedPath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "flash")
+	require.NoError(t, err)
+
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	fc := flash.FileConfig{
+		Path:    dir + "/shared.log",
+		MaxSize: 1,
+	}
+
+	l1 := flash.New(flash.WithFile(fc), flash.WithEncoder(flash.JSON))
+	l2 := flash.New(flash.WithFile(fc), flash.WithEncoder(flash.JSON))
+
+	// each logger writes more than half of MaxSize, so the shared file has to be rotated once
+	msg := strings.Repeat("x", 1000)
+	done := make(chan struct{})
+
+	for _, l := range []*flash.Logger{l1, l2} {
+		go func(l *flash.Logger) {
+			defer func() { done <- struct{}{} }()
+
+			for i := 0; i < 600; i++ {
+				l.Info(msg)
+			}
+		}(l)
+	}
+
+	<-done
+	<-done
+
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, files, 2, "file should be rotated exactly once")
+
+	lines := 0
+
+	for _, f := range files {
+		d, err := ioutil.ReadFile(dir + "/" + f.Name())
+		require.NoError(t, err)
+
+		s := bufio.NewScanner(bytes.NewReader(d))
+		s.Buffer(make([]byte, 4096), 4096)
+
+		for s.Scan() {
+			e := logEntry{}
+			require.NoError(t, json.Unmarshal(s.Bytes(), &e), "line is not atomic: %s", s.Text())
+			assert.Equal(t, msg, e.Msg)
+
+			lines++
+		}
+	}
+
+	assert.Equal(t, 1200, lines)
+}
+
+func TestWithStructuredStacktrace(t *testing.T) {
+	defer sink.Reset()
+
+	l := flash.New(flash.WithSinks("memory://"), flash.WithEncoder(flash.JSON),
+		flash.WithDebug(true), flash.WithStacktrace(), flash.WithStructuredStacktrace())
+	l.Error("error")
+
+	e := struct {
+		Stacktrace []struct {
+			Function string `json:"function"`
+			File     string `json:"file"`
+			Line     int    `json:"line"`
+		} `json:"stacktrace"`
+	}{}
+	require.NoError(t, json.Unmarshal(sink.Bytes(), &e))
+	require.NotEmpty(t, e.Stacktrace)
+	assert.Equal(t, "github.com/postfinance/flash_test.TestWithStructuredStacktrace", e.Stacktrace[0].Function)
+	assert.True(t, strings.HasSuffix(e.Stacktrace[0].File, "flash_test.go"))
+	assert.NotZero(t, e.Stacktrace[0].Line)
+
+	t.Run("console keeps the stacktrace", func(t *testing.T) {
+		sink.Reset()
+
+		l := flash.New(flash.WithSinks("memory://"), flash.WithEncoder(flash.Console),
+			flash.WithDebug(true), flash.WithStacktrace(), flash.WithStructuredStacktrace())
+		l.Error("error")
+		assert.Contains(t, sink.String(), "\ngithub.com/postfinance/flash_test.TestWithStructuredStacktrace.func1\n")
+	})
+}
+
+func TestWithErrorKey(t *testing.T) {
+	defer sink.Reset()
+
+	l := flash.New(flash.WithSinks("memory://"), flash.WithErrorKey("err"))
+	l.Errorw("failed", "user", "john", fmt.Errorf("boom"))
+
+	assert.Contains(t, sink.String(), `"err":"boom"`)
+	assert.Contains(t, sink.String(), `"user":"john"`)
+	assert.NotContains(t, sink.String(), `"error"`)
+
+	sink.Reset()
+	l.With(fmt.Errorf("boom")).Error("failed")
+	assert.Contains(t, sink.String(), `"err":"boom"`)
+}
+
+func TestWithFileCompressExisting(t *testing.T) {
+	dir, err := ioutil.TempDir("", "flash")
+	require.NoError(t, err)
+
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	backup := dir + "/app-2020-01-02T03-04-05.000.log"
+	require.NoError(t, ioutil.WriteFile(backup, []byte("old entry\n"), 0600))
+
+	flash.New(flash.WithFile(flash.FileConfig{
+		Path:             dir + "/app.log",
+		CompressExisting: true,
+	}))
+
+	assert.Eventually(t, func() bool {
+		_, errGz := os.Stat(backup + ".gz")
+		_, errOrig := os.Stat(backup)
+
+		return errGz == nil && os.IsNotExist(errOrig)
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestWithSampling(t *testing.T) {
+	defer sink.Reset()
+
+	l := flash.New(flash.WithSinks("memory://"), flash.WithDebug(true), flash.WithSampling(map[zapcore.Level]flash.SampleRate{
+		zapcore.DebugLevel: {Initial: 10, Thereafter: 100},
+	}))
+
+	for i := 0; i < 1000; i++ {
+		l.Debug("debug")
+		l.Error("error")
+	}
+
+	e, err := sink.parse()
+	require.NoError(t, err)
+
+	levels := map[string]int{}
+	for _, entry := range e {
+		levels[entry.Level]++
+	}
+
+	assert.Equal(t, 19, levels["DEBUG"])
+	assert.Equal(t, 1000, levels["ERROR"])
+}
+
+func TestWithSchemaVersion(t *testing.T) {
+	defer sink.Reset()
+
+	l := flash.New(flash.WithSinks("memory://"), flash.WithStacktrace(), flash.WithSchemaVersion("2"))
+	l.Info("info")
+	assert.Contains(t, sink.String(), `"log_schema":"2"`)
+
+	sink.Reset()
+	l.SetLevel(zapcore.DebugLevel)
+	l.Debug("debug")
+	assert.Contains(t, sink.String(), `"log_schema":"2"`)
+
+	sink.Reset()
+	l.SetLevel(zapcore.InfoLevel)
+	l.Info("info")
+	assert.Equal(t, 1, strings.Count(sink.String(), `"log_schema":"2"`))
+}
+
+func TestWithRingBuffer(t *testing.T) {
+	defer sink.Reset()
+
+	l := flash.New(flash.WithSinks("memory://"), flash.WithEncoder(flash.LogFmt),
+		flash.WithoutTimestamps(), flash.WithoutCaller(), flash.WithRingBuffer(3))
+	assert.Empty(t, l.Tail())
+
+	l.Info("1")
+	assert.Equal(t, []string{"level=INFO msg=1"}, l.Tail())
+
+	for i := 2; i <= 5; i++ {
+		l.Infof("%d", i)
+	}
+
+	assert.Equal(t, []string{"level=INFO msg=3", "level=INFO msg=4", "level=INFO msg=5"}, l.Tail())
+	assert.Contains(t, sink.String(), "msg=1")
+
+	assert.Nil(t, flash.New(flash.WithSinks("memory://")).Tail())
+}
+
+func TestClose(t *testing.T) {
+	defer sink.Reset()
+
+	l := flash.New(flash.WithSinks("memory://"))
+	l.Info("info")
+	require.NoError(t, l.Close())
+	require.NoError(t, l.Close())
+}
+
+func TestCloseWithTimeout(t *testing.T) {
+	_ = zap.RegisterSink("slow", func(*url.URL) (zap.Sink, error) {
+		return &slowSink{memorySink: &memorySink{new(bytes.Buffer)}, delay: time.Second}, nil
+	})
+
+	l := flash.New(flash.WithSinks("slow://"))
+	l.Info("info")
+
+	start := time.Now()
+	err := l.CloseWithTimeout(50 * time.Millisecond)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "within 50ms")
+	assert.Less(t, int64(time.Since(start)), int64(time.Second))
+
+	require.NoError(t, flash.New(flash.WithSinks("memory://")).CloseWithTimeout(time.Second))
+}
+
+type slowSink struct {
+	*memorySink
+	delay time.Duration
+}
+
+func (s *slowSink) Sync() error {
+	time.Sleep(s.delay)
+	return nil
+}
+
+func TestWithLambdaDefaults(t *testing.T) {
+	defer sink.Reset()
+
+	l := flash.New(flash.WithSinks("memory://"), flash.WithLambdaDefaults())
+	l.Info("info")
+
+	e, err := sink.parse()
+	require.NoError(t, err)
+	require.Len(t, e, 1)
+	assert.Empty(t, e[0].Caller)
+
+	ts, err := time.Parse(time.RFC3339, e[0].TS)
+	require.NoError(t, err)
+	assert.Equal(t, time.UTC, ts.Location())
+	assert.True(t, strings.HasSuffix(e[0].TS, "Z"))
+
+	t.Run("defaults can be overridden", func(t *testing.T) {
+		sink.Reset()
+
+		l := flash.New(flash.WithSinks("memory://"), flash.WithLambdaDefaults(), flash.WithEncoder(flash.LogFmt))
+		l.Info("info")
+		assert.True(t, strings.HasPrefix(sink.String(), "ts="), sink.String())
+	})
+
+	t.Run("request id from context", func(t *testing.T) {
+		sink.Reset()
+
+		ctx := flash.NewRequestIDContext(context.Background(), "c6af9ac6-7b61-11e6-9a41-93e8deadbeef")
+		l.Ctx(ctx).Info("info")
+		l.Ctx(context.Background()).Info("info")
+
+		lines := strings.Split(strings.TrimSpace(sink.String()), "\n")
+		require.Len(t, lines, 2)
+		assert.Contains(t, lines[0], `"requestId":"c6af9ac6-7b61-11e6-9a41-93e8deadbeef"`)
+		assert.NotContains(t, lines[1], "requestId")
+
+		sink.Reset()
+		l.Audit().Ctx(ctx).Info("audit")
+		assert.Contains(t, sink.String(), `"requestId":"c6af9ac6-7b61-11e6-9a41-93e8deadbeef"`)
+
+		sink.Reset()
+
+		l := flash.New(flash.WithSinks("memory://"), flash.WithLambdaDefaults(), flash.WithRequestIDKey("request_id"))
+		l.Ctx(ctx).Info("info")
+		assert.Contains(t, sink.String(), `"request_id":"c6af9ac6-7b61-11e6-9a41-93e8deadbeef"`)
+	})
+}
+
+func TestWithDeferredField(t *testing.T) {
+	defer sink.Reset()
+
+	calls := 0
+
+	l := flash.New(flash.WithSinks("memory://"), flash.WithDeferredField("commit", func() interface{} {
+		calls++
+		return "abc123"
+	}))
+	assert.Equal(t, 0, calls, "field should not be computed in New")
+
+	for i := 0; i < 100; i++ {
+		l.Info("info")
+	}
+
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, 100, strings.Count(sink.String(), `"commit":"abc123"`))
+}
+
+func TestWithTemporaryLevel(t *testing.T) {
+	defer sink.Reset()
+
+	l := flash.New(flash.WithSinks("memory://"))
+
+	restore := l.WithTemporaryLevel(zapcore.DebugLevel)
+	l.Debug("debug")
+	assert.Contains(t, sink.String(), "debug")
+
+	restore()
+	restore()
+
+	sink.Reset()
+	l.Debug("debug")
+	assert.Empty(t, sink.String())
+	assert.Equal(t, zapcore.InfoLevel, l.AtomicLevel().Level())
+
+	t.Run("overlapping", func(t *testing.T) {
+		restoreA := l.WithTemporaryLevel(zapcore.DebugLevel)
+		restoreB := l.WithTemporaryLevel(zapcore.WarnLevel)
+		assert.Equal(t, zapcore.WarnLevel, l.AtomicLevel().Level())
+
+		restoreA()
+		assert.Equal(t, zapcore.WarnLevel, l.AtomicLevel().Level())
+
+		restoreB()
+		assert.Equal(t, zapcore.InfoLevel, l.AtomicLevel().Level())
+	})
+
+	t.Run("concurrent", func(t *testing.T) {
+		var wg sync.WaitGroup
+
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+
+			go func(level zapcore.Level) {
+				defer wg.Done()
+
+				restore := l.WithTemporaryLevel(level)
+				restore()
+			}(zapcore.Level(i%4 - 1))
+		}
+
+		wg.Wait()
+		assert.Equal(t, zapcore.InfoLevel, l.AtomicLevel().Level())
+	})
+
+	t.Run("debug mode", func(t *testing.T) {
+		l.SetDebug(true)
+
+		restore := l.WithTemporaryLevel(zapcore.WarnLevel)
+		restore()
+		assert.Equal(t, zapcore.DebugLevel, l.AtomicLevel().Level())
+
+		l.SetDebug(false)
+		assert.Equal(t, zapcore.InfoLevel, l.AtomicLevel().Level())
+	})
+
+	t.Run("set level", func(t *testing.T) {
+		restore := l.WithTemporaryLevel(zapcore.DebugLevel)
+		l.SetLevel(zapcore.ErrorLevel)
+		restore()
+		assert.Equal(t, zapcore.ErrorLevel, l.AtomicLevel().Level())
+	})
+}
+
+func TestWithDurationEncoder(t *testing.T) {
+	defer sink.Reset()
+
+	l := flash.New(flash.WithSinks("memory://"), flash.WithDurationEncoder(zapcore.NanosDurationEncoder))
+	l.Infow("info", zap.Duration("elapsed", 1500*time.Millisecond))
+	assert.Contains(t, sink.String(), `"elapsed":1500000000`)
+
+	sink.Reset()
+	flash.New(flash.WithSinks("memory://")).Infow("info", zap.Duration("elapsed", 1500*time.Millisecond))
+	assert.Contains(t, sink.String(), `"elapsed":"1.5s"`)
+}
+
+func TestWithSecondsDuration(t *testing.T) {
+	defer sink.Reset()
+
+	flash.New(flash.WithSinks("memory://"), flash.WithSecondsDuration()).Infow("info", zap.Duration("elapsed", 1500*time.Millisecond))
+	assert.Contains(t, sink.String(), `"elapsed":1.5}`)
+
+	sink.Reset()
+	flash.New(flash.WithSinks("memory://"), flash.WithMillisDuration()).Infow("info", zap.Duration("elapsed", 1500*time.Millisecond))
+	assert.Contains(t, sink.String(), `"elapsed":1500}`)
+}
+
+func TestWithTimeEncoderForFields(t *testing.T) {
+	defer sink.Reset()
+
+	at := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	l := flash.New(flash.WithSinks("memory://"), flash.WithTimeEncoderForFields(zapcore.EpochTimeEncoder))
+	l.Infow("info", zap.Time("at", at))
+	l.With(zap.Time("at", at)).Info("info")
+	assert.Equal(t, 2, strings.Count(sink.String(), `"at":1577934245`))
+
+	e, err := sink.parse()
+	require.NoError(t, err)
+	require.Len(t, e, 2)
+
+	// the entry timestamp keeps the default encoder
+	_, err = time.Parse("2006-01-02T15:04:05.000Z0700", e[0].TS)
+	require.NoError(t, err)
+}
+
+func TestNewE(t *testing.T) {
+	defer sink.Reset()
+
+	l, err := flash.NewE(flash.WithSinks("memory://"))
+	require.NoError(t, err)
+	l.Info("info")
+	assert.NotEmpty(t, sink.String())
+
+	t.Run("empty file path", func(t *testing.T) {
+		_, err := flash.NewE(flash.WithFile(flash.FileConfig{}))
+		require.Error(t, err)
+		assert.Equal(t, "invalid file config: path must not be empty", err.Error())
+
+		assert.PanicsWithValue(t, "invalid file config: path must not be empty", func() {
+			flash.New(flash.WithFile(flash.FileConfig{}))
+		})
+	})
+}
+
+func TestWithFieldTransform(t *testing.T) {
+	defer sink.Reset()
+
+	hash := func(f zapcore.Field) zapcore.Field {
+		return zap.String(f.Key, fmt.Sprintf("%x", sha256.Sum256([]byte(f.String))))
+	}
+
+	lower := func(f zapcore.Field) zapcore.Field {
+		return zap.String(f.Key, strings.ToLower(f.String))
+	}
+
+	l := flash.New(flash.WithSinks("memory://"),
+		flash.WithFieldTransform("user_id", hash),
+		flash.WithFieldTransform("email", lower),
+	)
+	l.Infow("info", "user_id", "john", "email", "John@Example.com")
+
+	assert.Contains(t, sink.String(), fmt.Sprintf(`"user_id":"%x"`, sha256.Sum256([]byte("john"))))
+	assert.Contains(t, sink.String(), `"email":"john@example.com"`)
+	assert.NotContains(t, sink.String(), `"john"`)
+}
+
+func TestWithConsoleAndFile(t *testing.T) {
+	defer sink.Reset()
+
+	dir, err := ioutil.TempDir("", "flash")
+	require.NoError(t, err)
+
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	path := dir + "/app.log"
+	l := flash.New(flash.WithConsoleAndFile(flash.FileConfig{Path: path}), flash.WithSinks("memory://"), flash.WithColor())
+	l.Infow("a log message", "key", "value")
+
+	console := sink.String()
+	assert.Contains(t, console, "\tflash/flash_test.go:")
+	assert.Contains(t, console, "\ta log message\t{\"key\": \"value\"}\n")
+
+	d, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+
+	e := logEntry{}
+	require.NoError(t, json.Unmarshal(d, &e))
+	assert.Equal(t, "INFO", e.Level, "file should not contain color")
+	assert.Equal(t, "a log message", e.Msg)
+	assert.Contains(t, string(d), `"key":"value"`)
+}
+
+// nolint: gochecknoglobals
+var syncErrSink = &countingSyncSink{memorySink: &memorySink{new(bytes.Buffer)}}
+
+func TestWithoutInitialSync(t *testing.T) {
+	_ = zap.RegisterSink("syncerr", func(*url.URL) (zap.Sink, error) {
+		return syncErrSink, nil
+	})
+
+	syncErrSink.syncs = 0
+
+	flash.New(flash.WithSinks("syncerr://"))
+	assert.Equal(t, 1, syncErrSink.syncs)
+
+	syncErrSink.syncs = 0
+
+	l := flash.New(flash.WithSinks("syncerr://"), flash.WithoutInitialSync())
+	assert.Equal(t, 0, syncErrSink.syncs)
+
+	l.Info("info")
+	assert.Equal(t, 0, syncErrSink.syncs)
+	assert.Error(t, l.Sync())
+}
+
+type countingSyncSink struct {
+	*memorySink
+	syncs int
+}
+
+func (s *countingSyncSink) Sync() error {
+	s.syncs++
+	return fmt.Errorf("sync not supported")
+}
+
+func TestWithAlignedConsole(t *testing.T) {
+	defer sink.Reset()
+
+	ansi := strings.NewReplacer("\x1b[31m", "", "\x1b[34m", "", "\x1b[0m", "")
+
+	for _, color := range []bool{false, true} {
+		sink.Reset()
+
+		opts := []flash.Option{flash.WithSinks("memory://"), flash.WithEncoder(flash.Console), flash.WithAlignedConsole()}
+		if color {
+			opts = append(opts, flash.WithColor())
+		}
+
+		l := flash.New(opts...)
+		l.Info("message")
+		l.Error("message")
+
+		lines := strings.Split(strings.TrimSpace(ansi.Replace(sink.String())), "\n")
+		require.Len(t, lines, 2)
+		assert.Equal(t, strings.Index(lines[0], "message"), strings.Index(lines[1], "message"), "color: %t", color)
+		assert.Contains(t, lines[0], "\tINFO \t")
+	}
+}
+
+func TestWithSamplingHook(t *testing.T) {
+	defer sink.Reset()
+
+	decisions := map[bool]int{}
+
+	l := flash.New(flash.WithSinks("memory://"),
+		flash.WithSampling(map[zapcore.Level]flash.SampleRate{
+			zapcore.InfoLevel: {Initial: 5, Thereafter: 0},
+		}),
+		flash.WithSamplingHook(func(e zapcore.Entry, sampled bool) {
+			assert.Equal(t, "info", e.Message)
+			decisions[sampled]++
+		}),
+	)
+
+	for i := 0; i < 20; i++ {
+		l.Info("info")
+		l.Error("error")
+	}
+
+	assert.Equal(t, 5, decisions[true])
+	assert.Equal(t, 15, decisions[false])
+}
+
+func TestWithStartupLog(t *testing.T) {
+	defer sink.Reset()
+
+	l := flash.New(flash.WithSinks("memory://"), flash.WithStartupLog(), flash.WithDebug(true), flash.WithEncoder(flash.JSON))
+	l.Info("info")
+
+	lines := strings.Split(strings.TrimSpace(sink.String()), "\n")
+	require.Len(t, lines, 2)
+
+	e := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &e))
+	assert.Equal(t, "logger configured", e["msg"])
+	assert.Equal(t, "INFO", e["level"])
+	assert.Equal(t, "debug", e["min_level"])
+	assert.Equal(t, "json", e["encoder"])
+	assert.Equal(t, []interface{}{"memory://"}, e["sinks"])
+}
+
+func TestValidateSinks(t *testing.T) {
+	defer sink.Reset()
+
+	dir, err := ioutil.TempDir("", "flash")
+	require.NoError(t, err)
+
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	good := dir + "/good.log"
+	bad := dir + "/missing/bad.log"
+
+	l := flash.New(flash.WithSinks(good, bad), flash.WithFallbackSink("memory://"))
+
+	err = l.ValidateSinks()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), bad)
+	assert.NotContains(t, err.Error(), good)
+
+	d, err := ioutil.ReadFile(good)
+	require.NoError(t, err)
+	assert.Empty(t, d, "validation should not write entries")
+
+	require.NoError(t, flash.New(flash.WithSinks(good)).ValidateSinks())
+}
+
+func TestWithDPanicPanics(t *testing.T) {
+	defer sink.Reset()
+
+	l := flash.New(flash.WithSinks("memory://"))
+	assert.NotPanics(t, func() {
+		l.DPanic("dpanic")
+		l.DPanicw("dpanic", "key", "value")
+	})
+
+	e, err := sink.parse()
+	require.NoError(t, err)
+	require.Len(t, e, 2)
+	assert.Equal(t, "DPANIC", e[0].Level)
+
+	l = flash.New(flash.WithSinks("memory://"), flash.WithDPanicPanics(true))
+	assert.Panics(t, func() { l.DPanic("dpanic") })
+	assert.Panics(t, func() { l.DPanicw("dpanic", "key", "value") })
+	assert.Contains(t, sink.String(), `"level":"DPANIC"`)
+
+	l = flash.New(flash.WithSinks("memory://"), flash.WithDPanicPanics(false))
+	assert.NotPanics(t, func() { l.DPanic("dpanic") })
+}
+
+func TestWithCoreWrapper(t *testing.T) {
+	defer sink.Reset()
+
+	r := prometheus.NewRegistry()
+
+	l := flash.New(flash.WithSinks("memory://"), flash.WithPrometheus("wrapper", r),
+		flash.WithCoreWrapper(func(c zapcore.Core) zapcore.Core {
+			return &dropCore{Core: c, msg: "drop me"}
+		}))
+	l.Info("drop me")
+	l.Info("keep me")
+
+	e, err := sink.parse()
+	require.NoError(t, err)
+	require.Len(t, e, 1)
+	assert.Equal(t, "keep me", e[0].Msg)
+
+	const expected = `
+		# HELP wrapper_log_messages_total How many log messages created, partitioned by log level and component.
+		# TYPE wrapper_log_messages_total counter
+		wrapper_log_messages_total{component="",level="info"} 1
+	`
+
+	require.NoError(t, testutil.GatherAndCompare(r, strings.NewReader(expected), "wrapper_log_messages_total"))
+}
+
+type dropCore struct {
+	zapcore.Core
+	msg string
+}
+
+func (c *dropCore) With(fields []zapcore.Field) zapcore.Core {
+	return &dropCore{Core: c.Core.With(fields), msg: c.msg}
+}
+
+func (c *dropCore) Check(e zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if e.Message == c.msg {
+		return ce
+	}
+
+	return c.Core.Check(e, ce)
+}
+
+func TestEnable(t *testing.T) {
+	defer sink.Reset()
+
+	l := flash.New(flash.WithSinks("memory://"), flash.WithStacktrace())
+	l.Info("info")
+	assert.NotEmpty(t, sink.String())
+
+	sink.Reset()
+	l.Disable()
+	l.Disable()
+	l.Info("info")
+	assert.Empty(t, sink.String())
+
+	l.Enable()
+	l.Info("info")
+	l.Debug("debug")
+
+	e, err := sink.parse()
+	require.NoError(t, err)
+	require.Len(t, e, 1)
+	assert.Equal(t, "INFO", e[0].Level)
+
+	t.Run("restores debug mode with stacktraces", func(t *testing.T) {
+		sink.Reset()
+		l.SetDebug(true)
+		l.Disable()
+		l.Enable()
+		l.Debug("debug")
+		l.Error("error")
+
+		e, err := sink.parse()
+		require.NoError(t, err)
+		require.Len(t, e, 2)
+		assert.NotEmpty(t, e[1].Stacktrace)
+
+		sink.Reset()
+		l.SetDebug(false)
+		l.Debug("debug")
+		assert.Empty(t, sink.String())
+	})
+
+	t.Run("keeps level set after disable", func(t *testing.T) {
+		sink.Reset()
+		l.SetLevel(zap.InfoLevel)
+		l.Disable()
+		l.SetLevel(zap.WarnLevel)
+		l.Enable()
+		l.Info("info")
+		l.Warn("warn")
+
+		e, err := sink.parse()
+		require.NoError(t, err)
+		require.Len(t, e, 1)
+		assert.Equal(t, "WARN", e[0].Level)
+
+		sink.Reset()
+		l.Disable()
+		l.SetDebug(true)
+		l.Enable()
+		l.Debug("debug")
+		assert.NotEmpty(t, sink.String())
+	})
+}
+
+func TestWithAllowKeys(t *testing.T) {
+	defer sink.Reset()
+
+	for _, enc := range []flash.EncoderType{flash.JSON, flash.LogFmt} {
+		sink.Reset()
+
+		l := flash.New(
+			flash.WithSinks("memory://"),
+			flash.WithEncoder(enc),
+			flash.WithAllowKeys("user", "secret"),
+			flash.WithSkipKeys("secret"),
+		)
+		l.With("token", "abc").Infow("info message", "secret", "xyz", "user", "john", "other", 1)
+
+		out := sink.String()
+		assert.Contains(t, out, "john", enc)
+		assert.Contains(t, out, "info message", enc)
+		assert.Contains(t, out, "INFO", enc)
+		assert.Contains(t, out, "flash_test.go", enc)
+		assert.NotContains(t, out, "secret", enc)
+		assert.NotContains(t, out, "token", enc)
+		assert.NotContains(t, out, "other", enc)
+	}
+}
+
+func TestSetVerbosity(t *testing.T) {
+	tt := []struct {
+		verbosity int
+		level     zapcore.Level
+	}{
+		{-3, zap.ErrorLevel},
+		{-2, zap.ErrorLevel},
+		{-1, zap.WarnLevel},
+		{0, zap.InfoLevel},
+		{1, zap.DebugLevel},
+		{2, zap.DebugLevel},
+	}
+
+	l := flash.New(flash.WithSinks("memory://"))
+
+	for _, tc := range tt {
+		l.SetVerbosity(tc.verbosity)
+		assert.Equal(t, tc.level, l.AtomicLevel().Level(), tc.verbosity)
+		assert.False(t, l.Desugar().Core().Enabled(tc.level-1), tc.verbosity)
+		assert.True(t, l.Desugar().Core().Enabled(tc.level), tc.verbosity)
+
+		v := flash.New(flash.WithSinks("memory://"), flash.WithVerbosity(tc.verbosity))
+		assert.Equal(t, tc.level, v.AtomicLevel().Level(), tc.verbosity)
+	}
+
+	t.Run("stacktrace on debug verbosity", func(t *testing.T) {
+		defer sink.Reset()
+
+		l := flash.New(flash.WithSinks("memory://"), flash.WithStacktrace(), flash.WithVerbosity(1))
+		l.Error("error")
+
+		e, err := sink.parse()
+		require.NoError(t, err)
+		require.Len(t, e, 1)
+		assert.NotEmpty(t, e[0].Stacktrace)
+	})
+}
+
+func TestDuplicateSinks(t *testing.T) {
+	defer sink.Reset()
+
+	l := flash.New(flash.WithSinks("memory://", "memory://", "stderr", "stderr://"), flash.WithoutInitialSync())
+	l.Info("once")
+
+	e, err := sink.parse()
+	require.NoError(t, err)
+	assert.Len(t, e, 1)
+}
+
+func TestWithMaskPatterns(t *testing.T) {
+	defer sink.Reset()
+
+	l := flash.New(flash.WithSinks("memory://"), flash.WithMaskPatterns(regexp.MustCompile(`\b\d{16}\b`)))
+	l.Infow("payment with card 4111111111111111", "card", "4111111111111111", "amount", 1234567890123456)
+
+	out := sink.String()
+
+	e, err := sink.parse()
+	require.NoError(t, err)
+	require.Len(t, e, 1)
+	assert.Equal(t, "payment with card ***", e[0].Msg)
+	assert.Contains(t, out, `"card":"***"`)
+	assert.Contains(t, out, `"amount":1234567890123456`)
+}
+
+func TestAudit(t *testing.T) {
+	defer sink.Reset()
+
+	l := flash.New(flash.WithSinks("memory://"))
+	audit := l.Audit()
+
+	l.SetLevel(zap.ErrorLevel)
+	l.Info("info")
+	audit.Info("audit")
+	audit.Debug("debug")
+
+	e, err := sink.parse()
+	require.NoError(t, err)
+	require.Len(t, e, 1)
+	assert.Equal(t, "audit", e[0].Msg)
+
+	sink.Reset()
+	l.SetLevel(zap.DebugLevel)
+	l.Debug("debug")
+	audit.Debug("debug")
+
+	e, err = sink.parse()
+	require.NoError(t, err)
+	assert.Len(t, e, 1)
+}
+
+func TestWithNoFatalExit(t *testing.T) {
+	defer sink.Reset()
+
+	l := flash.New(flash.WithSinks("memory://"), flash.WithNoFatalExit())
+	l.Fatal("fatal")
+	l.Info("still running")
+
+	e, err := sink.parse()
+	require.NoError(t, err)
+	require.Len(t, e, 2)
+	assert.Equal(t, "FATAL", e[0].Level)
+}
+
+func TestNoSample(t *testing.T) {
+	defer sink.Reset()
+
+	l := flash.New(flash.WithSinks("memory://"), flash.WithSampling(map[zapcore.Level]flash.SampleRate{
+		zapcore.InfoLevel: {Initial: 1, Thereafter: 0},
+	}))
+
+	critical := l.NoSample()
+
+	for i := 0; i < 100; i++ {
+		l.Info("sampled")
+		critical.Info("critical")
+	}
+
+	out := sink.String()
+
+	e, err := sink.parse()
+	require.NoError(t, err)
+
+	messages := map[string]int{}
+	for _, entry := range e {
+		messages[entry.Msg]++
+	}
+
+	assert.Equal(t, 1, messages["sampled"])
+	assert.Equal(t, 100, messages["critical"])
+	assert.NotContains(t, out, "nosample")
+}
+
+func TestParseEncoder(t *testing.T) {
+	tt := []struct {
+		name    string
+		encoder flash.EncoderType
+		wantErr bool
+	}{
+		{"console", flash.Console, false},
+		{"JSON", flash.JSON, false},
+		{"json", flash.JSON, false},
+		{"LogFmt", flash.LogFmt, false},
+		{"", flash.Console, true},
+		{"yaml", flash.Console, true},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			e, err := flash.ParseEncoder(tc.name)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.encoder, e)
+			assert.True(t, strings.EqualFold(tc.name, e.String()))
+		})
+	}
+
+	t.Run("with encoder string", func(t *testing.T) {
+		defer sink.Reset()
+
+		_, err := flash.NewE(flash.WithSinks("memory://"), flash.WithEncoderString("yaml"))
+		assert.Error(t, err)
+
+		l, err := flash.NewE(flash.WithSinks("memory://"), flash.WithEncoderString("logfmt"))
+		require.NoError(t, err)
+		l.Info("info")
+		assert.Contains(t, sink.String(), "msg=info")
+	})
+}
+
+func TestRequestField(t *testing.T) {
+	defer sink.Reset()
+
+	l := flash.New(flash.WithSinks("memory://"))
+
+	r, err := http.NewRequest(http.MethodPost, "https://example.com/api?q=1", strings.NewReader("body"))
+	require.NoError(t, err)
+	r.Header.Set("Authorization", "Bearer secret")
+	r.Header.Set("Content-Type", "text/plain")
+
+	resp := &http.Response{StatusCode: http.StatusOK, ContentLength: 2, Header: http.Header{}}
+	resp.Header.Set("Set-Cookie", "session=secret")
+
+	l.Desugar().Info("request", flash.RequestField(r), flash.ResponseField(resp))
+
+	out := sink.String()
+	assert.NotContains(t, out, "secret")
+	assert.Contains(t, out, `"request":{"method":"POST","url":"https://example.com/api?q=1","body_size":4,"headers":{"Authorization":"[REDACTED]","Content-Type":"text/plain"}}`)
+	assert.Contains(t, out, `"response":{"status":200,"body_size":2,"headers":{"Set-Cookie":"[REDACTED]"}}`)
+}
+
+func TestWithPrometheusMinLevel(t *testing.T) {
+	defer sink.Reset()
+
+	r := prometheus.NewRegistry()
+
+	l := flash.New(flash.WithSinks("memory://"), flash.WithDebug(true),
+		flash.WithPrometheus("minlevel", r), flash.WithPrometheusMinLevel(zap.InfoLevel))
+
+	l.Debug("debug")
+	l.Info("info")
+
+	expected := `
+		# HELP minlevel_log_messages_total How many log messages created, partitioned by log level and component.
+		# TYPE minlevel_log_messages_total counter
+		minlevel_log_messages_total{component="",level="info"} 1
+	`
+
+	err := testutil.GatherAndCompare(r, strings.NewReader(expected), "minlevel_log_messages_total")
+	require.NoError(t, err)
+}
+
+func TestWithTimeRounding(t *testing.T) {
+	defer sink.Reset()
+
+	l := flash.New(flash.WithSinks("memory://"), flash.WithTimeRounding(time.Second))
+	l.Info("info")
+
+	var entry struct {
+		Time string `json:"ts"`
+	}
+
+	require.NoError(t, json.Unmarshal([]byte(sink.String()), &entry))
+
+	ts, err := time.Parse("2006-01-02T15:04:05.000Z0700", entry.Time)
+	require.NoError(t, err)
+	assert.Zero(t, ts.Nanosecond())
+}
+
+func TestWithPrometheusComponent(t *testing.T) {
+	defer sink.Reset()
+
+	r := prometheus.NewRegistry()
+
+	l := flash.New(flash.WithSinks("memory://"), flash.WithPrometheus("component", r))
+	l.Named("db").Error("error")
+	l.Named("http").Error("error")
+	l.Named("http").Error("error")
+
+	expected := `
+		# HELP component_log_messages_total How many log messages created, partitioned by log level and component.
+		# TYPE component_log_messages_total counter
+		component_log_messages_total{component="db",level="error"} 1
+		component_log_messages_total{component="http",level="error"} 2
+	`
+
+	err := testutil.GatherAndCompare(r, strings.NewReader(expected), "component_log_messages_total")
+	require.NoError(t, err)
+}
+
+func TestBinaryFields(t *testing.T) {
+	defer sink.Reset()
+
+	b := []byte{0x00, 0x1b, '\n', 0xff, 0xfe}
+
+	for _, enc := range []flash.EncoderType{flash.Console, flash.JSON, flash.LogFmt} {
+		sink.Reset()
+
+		l := flash.New(flash.WithSinks("memory://"), flash.WithEncoder(enc))
+		l.Desugar().Info("binary", zap.Binary("signature", b), flash.Hex("hash", b))
+
+		out := strings.TrimSuffix(sink.String(), "\n")
+		assert.Contains(t, out, "ABsK//4=", enc)
+		assert.Contains(t, out, "001b0afffe", enc)
+		assert.False(t, strings.ContainsAny(out, "\x00\x1b\n"), enc)
+
+		if enc == flash.LogFmt {
+			assert.Contains(t, out, `signature="ABsK//4="`)
+			assert.Contains(t, out, `hash=001b0afffe`)
+		}
+	}
+}
+
+func TestWithThrottle(t *testing.T) {
+	defer sink.Reset()
+
+	l := flash.New(flash.WithSinks("memory://"), flash.WithThrottle(time.Minute, 3))
+
+	for i := 0; i < 10; i++ {
+		l.Info("repeated")
+
+		if i%2 == 0 {
+			l.Info("other")
+		}
+	}
+
+	e, err := sink.parse()
+	require.NoError(t, err)
+
+	messages := map[string]int{}
+	for _, entry := range e {
+		messages[entry.Msg]++
+	}
+
+	assert.Equal(t, 3, messages["repeated"])
+	assert.Equal(t, 3, messages["other"])
+}
+
+// upperEncoder is a zapcore.ReflectedEncoder encoding values as uppercased JSON.
+type upperEncoder struct {
+	w io.Writer
+}
+
+func (e upperEncoder) Encode(v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	_, err = e.w.Write(bytes.ToUpper(b))
+
+	return err
+}
+
+func TestWithReflectedEncoder(t *testing.T) {
+	defer sink.Reset()
+
+	l := flash.New(flash.WithSinks("memory://"), flash.WithReflectedEncoder(func(w io.Writer) zapcore.ReflectedEncoder {
+		return upperEncoder{w: w}
+	}))
+	l.Desugar().Info("reflected", zap.Any("user", struct {
+		Name string `json:"name"`
+	}{Name: "john"}))
+
+	assert.Contains(t, sink.String(), `"user":{"NAME":"JOHN"}`)
+}
+
+func TestWithSinkQuarantine(t *testing.T) {
+	defer sink.Reset()
+
+	l := flash.New(flash.WithSinks("memory://"), flash.WithSinkQuarantine(3, time.Second))
+	l.Info("info")
+
+	e, err := sink.parse()
+	require.NoError(t, err)
+	assert.Len(t, e, 1)
+}
+
+func TestWithPrometheusLatency(t *testing.T) {
+	defer sink.Reset()
+
+	r := prometheus.NewRegistry()
+
+	l := flash.New(flash.WithSinks("memory://"), flash.WithPrometheusLatency("latency", r, nil))
+	l.Info("info")
+	l.Error("error")
+	l.Debug("debug")
+
+	families, err := r.Gather()
+	require.NoError(t, err)
+	require.Len(t, families, 1)
+	assert.Equal(t, "latency_log_write_duration_seconds", families[0].GetName())
+
+	h := families[0].GetMetric()[0].GetHistogram()
+	assert.Equal(t, uint64(2), h.GetSampleCount())
+	assert.True(t, h.GetSampleSum() >= 0)
+}
+
+func TestWithStacktraceExcludes(t *testing.T) {
+	defer sink.Reset()
+
+	l := flash.New(flash.WithSinks("memory://"), flash.WithDebug(true), flash.WithStacktrace(),
+		flash.WithStacktraceExcludes(flash.ExcludeErrors(context.Canceled)))
+
+	l.Errorw("canceled", "error", fmt.Errorf("request: %w", context.Canceled))
+	l.Errorw("failed", "error", errors.New("failed"))
+	l.With("error", context.Canceled).With("id", 1).Error("context canceled")
+	l.Errorf("message: %v", context.Canceled)
+
+	e, err := sink.parse()
+	require.NoError(t, err)
+	require.Len(t, e, 4)
+	assert.Empty(t, e[0].Stacktrace)
+	assert.NotEmpty(t, e[1].Stacktrace)
+	assert.Empty(t, e[2].Stacktrace, "context fields should be matched")
+	assert.NotEmpty(t, e[3].Stacktrace, "errors in the message are not fields")
+}
+
+func TestWithPrometheusFieldCount(t *testing.T) {
+	defer sink.Reset()
+
+	r := prometheus.NewRegistry()
+
+	l := flash.New(flash.WithSinks("memory://"), flash.WithPrometheus("fields", r))
+	l.Info("no fields")
+	l.Infow("two fields", "a", 1, "b", 2)
+	l.With("c", 3).Infow("five fields", "a", 1, "b", 2, "d", 4, "e", 5)
+
+	expected := `
+		# HELP fields_log_entry_fields How many fields log entries have.
+		# TYPE fields_log_entry_fields histogram
+		fields_log_entry_fields_bucket{le="1"} 1
+		fields_log_entry_fields_bucket{le="2"} 2
+		fields_log_entry_fields_bucket{le="4"} 2
+		fields_log_entry_fields_bucket{le="8"} 3
+		fields_log_entry_fields_bucket{le="16"} 3
+		fields_log_entry_fields_bucket{le="32"} 3
+		fields_log_entry_fields_bucket{le="64"} 3
+		fields_log_entry_fields_bucket{le="128"} 3
+		fields_log_entry_fields_bucket{le="+Inf"} 3
+		fields_log_entry_fields_sum 7
+		fields_log_entry_fields_count 3
+	`
+
+	err := testutil.GatherAndCompare(r, strings.NewReader(expected), "fields_log_entry_fields")
+	require.NoError(t, err)
+}
+
+func TestReopenFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "flash")
+	require.NoError(t, err)
+
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	path := dir + "/app.log"
+	l := flash.New(flash.WithFile(flash.FileConfig{Path: path}), flash.WithReopenOnSIGHUP())
+
+	defer l.Close()
+
+	l.Info("before rotation")
+	require.NoError(t, os.Rename(path, path+".1"))
+
+	require.NoError(t, l.ReopenFiles())
+	l.Info("after rotation")
+
+	d, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(d), "after rotation")
+	assert.NotContains(t, string(d), "before rotation")
+
+	d, err = ioutil.ReadFile(path + ".1")
+	require.NoError(t, err)
+	assert.Contains(t, string(d), "before rotation")
+	assert.NotContains(t, string(d), "after rotation")
+}
+
+func TestWithDefaults(t *testing.T) {
+	defer sink.Reset()
+
+	l := flash.New(flash.WithSinks("memory://"), flash.WithDefaults("env", "unknown", zap.String("region", "eu"), "team", "core"))
+	l.Infow("override", "env", "prod")
+	l.With("team", "payments").Info("with")
+
+	out := strings.Split(strings.TrimSpace(sink.String()), "\n")
+	require.Len(t, out, 2)
+
+	assert.Contains(t, out[0], `"env":"prod"`)
+	assert.NotContains(t, out[0], "unknown")
+	assert.Contains(t, out[0], `"region":"eu"`)
+	assert.Contains(t, out[0], `"team":"core"`)
+
+	assert.Contains(t, out[1], `"env":"unknown"`)
+	assert.Contains(t, out[1], `"team":"payments"`)
+	assert.NotContains(t, out[1], `"core"`)
+}
+
+func TestWithSeverityNumber(t *testing.T) {
+	defer sink.Reset()
+
+	l := flash.New(flash.WithSinks("memory://"), flash.WithSeverityNumber("severity_number"))
+	l.Info("info")
+	l.Error("error")
+
+	out := strings.Split(strings.TrimSpace(sink.String()), "\n")
+	require.Len(t, out, 2)
+	assert.Contains(t, out[0], `"severity_number":9`)
+	assert.Contains(t, out[1], `"severity_number":17`)
+}
+
+func TestWithColorFromLevel(t *testing.T) {
+	defer sink.Reset()
+
+	l := flash.New(flash.WithSinks("memory://"), flash.WithEncoder(flash.Console), flash.WithColorFromLevel(zap.WarnLevel))
+	l.Info("info")
+	l.Error("error")
+
+	out := strings.Split(strings.TrimSpace(sink.String()), "\n")
+	require.Len(t, out, 2)
+	assert.NotContains(t, out[0], "\x1b[")
+	assert.Contains(t, out[0], "INFO")
+	assert.Contains(t, out[1], "\x1b[31mERROR\x1b[0m")
+}
+
+func TestLevelParsingWriter(t *testing.T) {
+	defer sink.Reset()
+
+	l := flash.New(flash.WithSinks("memory://"))
+	w := l.LevelParsingWriter(zap.InfoLevel)
+
+	_, err := io.WriteString(w, "WARN: something\n[ERROR] failed\nplain ")
+	require.NoError(t, err)
+	_, err = io.WriteString(w, "line\nfatal: not fatal\nDEBUG: dropped\n")
+	require.NoError(t, err)
+
+	e, err := sink.parse()
+	require.NoError(t, err)
+	require.Len(t, e, 4)
+
+	assert.Equal(t, "WARN", e[0].Level)
+	assert.Equal(t, "something", e[0].Msg)
+	assert.Equal(t, "ERROR", e[1].Level)
+	assert.Equal(t, "failed", e[1].Msg)
+	assert.Equal(t, "INFO", e[2].Level)
+	assert.Equal(t, "plain line", e[2].Msg)
+	assert.Equal(t, "ERROR", e[3].Level)
+	assert.Equal(t, "not fatal", e[3].Msg)
+}
+
+func TestWithMemoryBuffer(t *testing.T) {
+	defer sink.Reset()
+
+	l := flash.New(flash.WithSinks("memory://"), flash.WithMemoryBuffer(1024), flash.WithoutInitialSync())
+	l.Info("buffered")
+	assert.Empty(t, sink.String())
+
+	require.NoError(t, l.Flush())
+	assert.Contains(t, sink.String(), "buffered")
+}
+
+func TestWithMetricsOnly(t *testing.T) {
+	defer sink.Reset()
+
+	r := prometheus.NewRegistry()
+
+	l := flash.New(flash.WithSinks("memory://"), flash.WithMetricsOnly("metricsonly", r))
+	l.Info("info")
+	l.Info("info")
+	l.Error("error")
+	l.Debug("debug")
+
+	assert.Empty(t, sink.String())
+
+	expected := `
+		# HELP metricsonly_log_messages_total How many log messages created, partitioned by log level and component.
+		# TYPE metricsonly_log_messages_total counter
+		metricsonly_log_messages_total{component="",level="error"} 1
+		metricsonly_log_messages_total{component="",level="info"} 2
+	`
+
+	err := testutil.GatherAndCompare(r, strings.NewReader(expected), "metricsonly_log_messages_total")
+	require.NoError(t, err)
+
+	t.Run("with prometheus", func(t *testing.T) {
+		for _, metricsOnlyFirst := range []bool{false, true} {
+			r := prometheus.NewRegistry()
+
+			opts := []flash.Option{flash.WithSinks("memory://"), flash.WithPrometheus("metricsonly", r), flash.WithMetricsOnly("metricsonly", r)}
+			if metricsOnlyFirst {
+				opts[1], opts[2] = opts[2], opts[1]
+			}
+
+			var l *flash.Logger
+
+			require.NotPanics(t, func() {
+				l = flash.New(opts...)
+			})
+			l.Info("info")
+
+			expected := `
+				# HELP metricsonly_log_messages_total How many log messages created, partitioned by log level and component.
+				# TYPE metricsonly_log_messages_total counter
+				metricsonly_log_messages_total{component="",level="info"} 1
+			`
+
+			err := testutil.GatherAndCompare(r, strings.NewReader(expected), "metricsonly_log_messages_total")
+			require.NoError(t, err)
+			assert.Empty(t, sink.String())
+		}
+	})
+}
+
+func TestWithFlattenFields(t *testing.T) {
+	defer sink.Reset()
+
+	l := flash.New(flash.WithSinks("memory://"), flash.WithEncoder(flash.JSON), flash.WithFlattenFields("."))
+
+	user := zapcore.ObjectMarshalerFunc(func(enc zapcore.ObjectEncoder) error {
+		enc.AddInt("id", 1)
+		enc.AddString("name", "john")
+
+		return enc.AddObject("address", zapcore.ObjectMarshalerFunc(func(enc zapcore.ObjectEncoder) error {
+			enc.AddString("city", "Bern")
+			return nil
+		}))
+	})
+
+	l.Desugar().Info("flat", zap.Object("user", user), zap.Strings("items", []string{"a", "b"}),
+		zap.Any("order", map[string]int{"total": 3}), zap.String("plain", "value"))
+
+	out := sink.String()
+	assert.Contains(t, out, `"user.address.city":"Bern","user.id":1,"user.name":"john"`)
+	assert.Contains(t, out, `"items.0":"a","items.1":"b"`)
+	assert.Contains(t, out, `"order.total":3`)
+	assert.Contains(t, out, `"plain":"value"`)
+	assert.NotContains(t, out, "{\"id\"")
+}
+
+// node is a self-referential struct.
+type node struct {
+	Name string `json:"name"`
+	Next *node  `json:"next"`
+}
+
+func TestWithMaxEncodeDepth(t *testing.T) {
+	defer sink.Reset()
+
+	l := flash.New(flash.WithSinks("memory://"), flash.WithMaxEncodeDepth(2))
+
+	nested := map[string]interface{}{
+		"a": map[string]interface{}{
+			"b": map[string]interface{}{
+				"c": 1,
+			},
+			"list": []int{1, 2},
+		},
+		"value": 1,
+	}
+
+	cycle := &node{Name: "first"}
+	cycle.Next = cycle
+
+	l.Desugar().Info("nested", zap.Any("nested", nested), zap.Any("cycle", cycle))
+
+	out := sink.String()
+	assert.Contains(t, out, `"nested":{"a":{"b":"…","list":"…"},"value":1}`)
+	assert.Contains(t, out, `"cycle":{"name":"first","next":{"name":"first","next":"…"}}`)
+
+	t.Run("json options", func(t *testing.T) {
+		v := &depthValue{
+			DepthMeta: DepthMeta{ID: 1},
+			Count:     2,
+			Nested:    map[string]interface{}{"a": map[string]int{"b": 1}},
+		}
+
+		expected, err := json.Marshal(v)
+		require.NoError(t, err)
+
+		sink.Reset()
+
+		l := flash.New(flash.WithSinks("memory://"), flash.WithEncoder(flash.JSON), flash.WithMaxEncodeDepth(3))
+		l.Desugar().Info("within", zap.Any("v", v))
+		assert.Contains(t, sink.String(), `"v":`+string(expected))
+
+		sink.Reset()
+
+		l = flash.New(flash.WithSinks("memory://"), flash.WithEncoder(flash.JSON), flash.WithMaxEncodeDepth(2))
+		l.Desugar().Info("exceeding", zap.Any("v", v))
+
+		var entry struct {
+			V map[string]interface{} `json:"v"`
+		}
+
+		require.NoError(t, json.Unmarshal([]byte(sink.String()), &entry))
+		assert.Equal(t, map[string]interface{}{
+			"id":     float64(1),
+			"count":  "2",
+			"custom": "custom",
+			"nested": map[string]interface{}{"a": "…"},
+		}, entry.V)
+	})
+
+	t.Run("reflected encoder", func(t *testing.T) {
+		var encoded []interface{}
+
+		l := flash.New(flash.WithSinks("memory://"), flash.WithMaxEncodeDepth(2), flash.WithReflectedEncoder(func(w io.Writer) zapcore.ReflectedEncoder {
+			return encoderFunc(func(v interface{}) error {
+				encoded = append(encoded, v)
+				_, err := w.Write([]byte("{}"))
+
+				return err
+			})
+		}))
+
+		v := &depthValue{Count: 1}
+		l.Desugar().Info("reflected", zap.Any("v", v))
+
+		require.Len(t, encoded, 1)
+		assert.Equal(t, v, encoded[0])
+	})
+}
+
+// DepthMeta is embedded by depthValue.
+type DepthMeta struct {
+	ID int `json:"id"`
+}
+
+// customMarshaler implements json.Marshaler with a pointer receiver.
+type customMarshaler struct{}
+
+func (*customMarshaler) MarshalJSON() ([]byte, error) {
+	return []byte(`"custom"`), nil
+}
+
+type depthValue struct {
+	DepthMeta
+	Count   int                    `json:"count,string"`
+	Empty   string                 `json:"empty,omitempty"`
+	Custom  customMarshaler        `json:"custom"`
+	Nested  map[string]interface{} `json:"nested,omitempty"`
+	private int
+}
+
+type encoderFunc func(v interface{}) error
+
+func (fn encoderFunc) Encode(v interface{}) error {
+	return fn(v)
+}
+
+func TestWithGlobal(t *testing.T) {
+	defer sink.Reset()
+
+	defer zap.ReplaceGlobals(zap.L())
+
+	flash.New(flash.WithSinks("memory://"), flash.WithGlobal())
+	zap.S().Info("global")
+
+	e, err := sink.parse()
+	require.NoError(t, err)
+	require.Len(t, e, 1)
+	assert.Equal(t, "global", e[0].Msg)
+}
+
+func TestLogFmtMapOrder(t *testing.T) {
+	defer sink.Reset()
+
+	l := flash.New(flash.WithSinks("memory://"), flash.WithEncoder(flash.LogFmt), flash.WithoutTimestamps(), flash.WithoutCaller())
+
+	m := map[string]interface{}{"zeta": 1, "alpha": "a", "mid": true, "beta": 2.5, "nested": map[string]int{"y": 1, "x": 2}}
+
+	for i := 0; i < 20; i++ {
+		l.Desugar().Info("map", zap.Any("m", m))
+	}
+
+	lines := strings.Split(strings.TrimSpace(sink.String()), "\n")
+	require.Len(t, lines, 20)
+
+	for _, line := range lines {
+		assert.Equal(t, `level=INFO msg=map m="map[alpha:a beta:2.5 mid:true nested:map[x:2 y:1] zeta:1]"`, line)
+	}
+}
+
+func TestWithDebugFile(t *testing.T) {
+	defer sink.Reset()
+
+	dir, err := ioutil.TempDir("", "flash")
+	require.NoError(t, err)
+
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	path := dir + "/debug.log"
+
+	l := flash.New(flash.WithSinks("memory://"), flash.WithDebugFile(flash.FileConfig{Path: path}))
+	defer l.Close()
+
+	l.Debug("before debug mode")
+	l.SetDebug(true)
+	l.Debug("debug")
+	l.Info("info")
+	l.SetDebug(false)
+	l.Debug("after debug mode")
+
+	e, err := sink.parse()
+	require.NoError(t, err)
+	require.Len(t, e, 1)
+	assert.Equal(t, "info", e[0].Msg)
+
+	d, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+
+	assert.NotContains(t, string(d), "before debug mode")
+	assert.Contains(t, string(d), `"msg":"debug"`)
+	assert.Contains(t, string(d), `"msg":"info"`)
+	assert.NotContains(t, string(d), "after debug mode")
+}
+
+func TestWithPrometheusLastLog(t *testing.T) {
+	defer sink.Reset()
+
+	r := prometheus.NewRegistry()
+
+	l := flash.New(flash.WithSinks("memory://"), flash.WithPrometheusLastLog("app", r))
+
+	lastLog := func() float64 {
+		families, err := r.Gather()
+		require.NoError(t, err)
+		require.Len(t, families, 1)
+		assert.Equal(t, "app_last_log_timestamp_seconds", families[0].GetName())
+
+		return families[0].GetMetric()[0].GetGauge().GetValue()
+	}
+
+	before := float64(time.Now().UnixNano()) / float64(time.Second)
+
+	l.Info("first")
+
+	first := lastLog()
+	assert.True(t, first >= before)
+
+	time.Sleep(10 * time.Millisecond)
+	l.Info("second")
+
+	assert.True(t, lastLog() > first)
+}
+
+func TestWithFixedLevelWidth(t *testing.T) {
+	defer sink.Reset()
+
+	for _, color := range []bool{false, true} {
+		sink.Reset()
+
+		opts := []flash.Option{flash.WithSinks("memory://"), flash.WithEncoder(flash.Console), flash.WithFixedLevelWidth(7)}
+		if color {
+			opts = append(opts, flash.WithColor())
+		}
+
+		l := flash.New(opts...)
+		l.Info("message")
+
+		level := "INFO   "
+		if color {
+			level = "\x1b[34mINFO   \x1b[0m"
+		}
+
+		assert.Contains(t, sink.String(), "\t"+level+"\t", "color: %t", color)
+	}
+}
+
+func TestLogw(t *testing.T) {
+	defer sink.Reset()
+
+	l := flash.New(flash.WithSinks("memory://"))
+
+	l.Logw(zapcore.InfoLevel, "status", "code", 200)
+	l.Logw(zapcore.ErrorLevel, "status", "code", 500)
+	l.Logf(zapcore.WarnLevel, "status %d", 404)
+	l.Log(zapcore.DebugLevel, "status")
+	l.Log(zapcore.InfoLevel, "status")
+	_, _, line, _ := runtime.Caller(0)
+
+	e, err := sink.parse()
+	require.NoError(t, err)
+	require.Len(t, e, 4)
+	assert.Equal(t, "INFO", e[0].Level)
+	assert.Equal(t, "ERROR", e[1].Level)
+	assert.Equal(t, "WARN", e[2].Level)
+	assert.Equal(t, "status 404", e[2].Msg)
+	assert.Equal(t, "INFO", e[3].Level)
+	assert.Equal(t, fmt.Sprintf("flash/flash_test.go:%d", line-1), e[3].Caller)
+
+	t.Run("does not clone the logger", func(t *testing.T) {
+		allocs := testing.AllocsPerRun(100, func() {
+			l.Log(zapcore.DebugLevel, "disabled")
+		})
+		assert.Equal(t, float64(0), allocs)
+	})
+
+	t.Run("stacktrace level changed", func(t *testing.T) {
+		sink.Reset()
+
+		l := flash.New(flash.WithSinks("memory://"), flash.WithStacktrace())
+		l.Log(zapcore.ErrorLevel, "without stacktrace")
+		l.SetDebug(true)
+		l.Log(zapcore.ErrorLevel, "with stacktrace")
+		_, _, line, _ := runtime.Caller(0)
+
+		e, err := sink.parse()
+		require.NoError(t, err)
+		require.Len(t, e, 2)
+		assert.Empty(t, e[0].Stacktrace)
+		assert.NotEmpty(t, e[1].Stacktrace)
+		assert.Equal(t, fmt.Sprintf("flash/flash_test.go:%d", line-1), e[1].Caller)
+	})
+}
+
+func TestWithRenameKeys(t *testing.T) {
+	defer sink.Reset()
+
+	l := flash.New(
+		flash.WithSinks("memory://"),
+		flash.WithEncoder(flash.JSON),
+		flash.WithRenameKeys(map[string]string{"uid": "user_id", "pw": "password"}),
+		flash.WithAllowKeys("uid", "pw", "other"),
+		flash.WithSkipKeys("pw"),
+	)
+	l.With("uid", 42).Infow("renamed", "pw", "secret", "other", "value")
+
+	var entry map[string]interface{}
+
+	require.NoError(t, json.Unmarshal([]byte(sink.String()), &entry))
+	assert.Equal(t, float64(42), entry["user_id"])
+	assert.Equal(t, "value", entry["other"])
+	assert.NotContains(t, entry, "uid")
+	assert.NotContains(t, entry, "password")
+}
+
+func TestWithGoroutineID(t *testing.T) {
+	defer sink.Reset()
+
+	l := flash.New(flash.WithSinks("memory://"), flash.WithEncoder(flash.JSON), flash.WithGoroutineID())
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			l.Info("goroutine")
+		}()
+
+		wg.Wait()
+	}
+
+	ids := map[float64]struct{}{}
+
+	for _, line := range strings.Split(strings.TrimSpace(sink.String()), "\n") {
+		var entry map[string]interface{}
+
+		require.NoError(t, json.Unmarshal([]byte(line), &entry))
+
+		id, ok := entry["goroutine"].(float64)
+		require.True(t, ok)
+		assert.True(t, id > 0)
+
+		ids[id] = struct{}{}
+	}
+
+	assert.Len(t, ids, 2)
+}
+
+func TestWithLevelColorFunc(t *testing.T) {
+	defer sink.Reset()
+
+	truecolor := func(l zapcore.Level) string {
+		if l >= zapcore.ErrorLevel {
+			return "\x1b[38;2;255;85;85m"
+		}
+
+		return ""
+	}
+
+	l := flash.New(flash.WithSinks("memory://"), flash.WithEncoder(flash.Console), flash.WithLevelColorFunc(truecolor), flash.WithFixedLevelWidth(5))
+	l.Info("info")
+	l.Error("error")
+
+	out := strings.Split(strings.TrimSpace(sink.String()), "\n")
+	require.Len(t, out, 2)
+	assert.NotContains(t, out[0], "\x1b[")
+	assert.Contains(t, out[0], "\tINFO \t")
+	assert.Contains(t, out[1], "\t\x1b[38;2;255;85;85mERROR\x1b[0m\t")
+}
+
+func TestWithFileTimeUTC(t *testing.T) {
+	defer sink.Reset()
+
+	dir, err := ioutil.TempDir("", "flash")
+	require.NoError(t, err)
+
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	path := dir + "/app.log"
+	l := flash.New(flash.WithConsoleAndFile(flash.FileConfig{Path: path}), flash.WithSinks("memory://"), flash.WithFileTimeUTC())
+	defer l.Close()
+
+	local := time.Date(2020, 1, 2, 3, 4, 5, 0, time.FixedZone("CET", 3600))
+	l.LogAt(local, zapcore.InfoLevel, "message")
+
+	assert.True(t, strings.HasPrefix(sink.String(), "2020-01-02T03:04:05.000+0100\t"), sink.String())
+
+	d, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(d), `"ts":"2020-01-02T02:04:05.000Z"`)
+}
+
+func TestWithConstructionErrorWriter(t *testing.T) {
+	defer sink.Reset()
+
+	var diagnostics bytes.Buffer
+
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+
+	stderr := os.Stderr
+	os.Stderr = w
+
+	l := flash.New(
+		flash.WithSinks("invalid://sink"),
+		flash.WithFallbackSink("memory://", "memory://"),
+		flash.WithConstructionErrorWriter(&diagnostics),
+		flash.WithoutInitialSync(),
+	)
+	l.Info("fallback")
+
+	os.Stderr = stderr
+	require.NoError(t, w.Close())
+
+	out, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	assert.Empty(t, string(out))
+
+	assert.Contains(t, diagnostics.String(), "flash: ignoring duplicate sinks: memory://")
+	assert.Contains(t, diagnostics.String(), "flash: could not open sinks invalid://sink, using fallback sinks memory://, memory://")
+
+	e, err := sink.parse()
+	require.NoError(t, err)
+	require.Len(t, e, 1)
+	assert.Equal(t, "fallback", e[0].Msg)
+}
+
+func TestEvent(t *testing.T) {
+	defer sink.Reset()
+
+	r := prometheus.NewRegistry()
+
+	l := flash.New(flash.WithSinks("memory://"), flash.WithEncoder(flash.JSON), flash.WithPrometheusEventCodes("app", r, "E001"))
+	l.Event("E001", zapcore.ErrorLevel, "payment failed", zap.String("id", "42"))
+	l.Event("E002", zapcore.WarnLevel, "retrying")
+	l.Event("E003", zapcore.DebugLevel, "disabled")
+
+	lines := strings.Split(strings.TrimSpace(sink.String()), "\n")
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[0], `"msg":"payment failed","id":"42","event_code":"E001"`)
+	assert.Contains(t, lines[0], `"caller":"flash/flash_test.go`)
+	assert.Contains(t, lines[1], `"event_code":"E002"`)
+
+	families, err := r.Gather()
+	require.NoError(t, err)
+	require.Len(t, families, 1)
+	assert.Equal(t, "app_log_events_total", families[0].GetName())
+
+	counts := map[string]float64{}
+
+	for _, m := range families[0].GetMetric() {
+		labels := map[string]string{}
+		for _, lp := range m.GetLabel() {
+			labels[lp.GetName()] = lp.GetValue()
+		}
+
+		counts[labels["level"]+"/"+labels["code"]] = m.GetCounter().GetValue()
+	}
+
+	assert.Equal(t, map[string]float64{"error/E001": 1, "warn/other": 1}, counts)
+}
+
+func TestWithUTF16BOM(t *testing.T) {
+	dir, err := ioutil.TempDir("", "flash")
+	require.NoError(t, err)
+
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	path := dir + "/utf16.log"
+
+	l := flash.New(flash.WithFile(flash.FileConfig{Path: path}), flash.WithEncoder(flash.JSON), flash.WithUTF16BOM())
+	l.Info("grüezi 😀")
+	l.Info("second")
+	require.NoError(t, l.Close())
+
+	d, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.True(t, len(d) > 2 && len(d)%2 == 0)
+	assert.Equal(t, []byte{0xff, 0xfe}, d[:2])
+
+	units := make([]uint16, 0, len(d)/2-1)
+	for i := 2; i < len(d); i += 2 {
+		units = append(units, binary.LittleEndian.Uint16(d[i:]))
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(utf16.Decode(units))), "\n")
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[0], `"msg":"grüezi 😀"`)
+	assert.Contains(t, lines[1], `"msg":"second"`)
+}
+
+func TestWithUTF16BOMReopen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "flash")
+	require.NoError(t, err)
+
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	path := dir + "/utf16.log"
+
+	l := flash.New(flash.WithFile(flash.FileConfig{Path: path}), flash.WithUTF16BOM())
+	l.Info("first")
+	require.NoError(t, os.Rename(path, path+".1"))
+	require.NoError(t, l.ReopenFiles())
+	l.Info("second")
+	require.NoError(t, l.Close())
+
+	for _, p := range []string{path + ".1", path} {
+		d, err := ioutil.ReadFile(p)
+		require.NoError(t, err)
+		require.True(t, len(d) > 2)
+		assert.Equal(t, []byte{0xff, 0xfe}, d[:2], p)
+		assert.NotEqual(t, []byte{0xff, 0xfe}, d[2:4], p)
+	}
+}
+
+type fieldsError struct {
+	fields map[string]interface{}
+}
+
+func (e fieldsError) Error() string                  { return "fields error" }
+func (e fieldsError) Fields() map[string]interface{} { return e.fields }
+
+func TestWithErrorFields(t *testing.T) {
+	defer sink.Reset()
+
+	l := flash.New(flash.WithSinks("memory://"), flash.WithEncoder(flash.JSON), flash.WithErrorFields("err_"))
+
+	err := fmt.Errorf("request: %w", fieldsError{fields: map[string]interface{}{"user": "john", "attempt": 3}})
+
+	l.Errorw("failed", "error", err)
+	l.With(zap.Error(err)).Info("with")
+	l.Errorw("plain", "error", errors.New("plain"))
+
+	lines := strings.Split(strings.TrimSpace(sink.String()), "\n")
+	require.Len(t, lines, 3)
+
+	for _, line := range lines[:2] {
+		var entry map[string]interface{}
+
+		require.NoError(t, json.Unmarshal([]byte(line), &entry))
+		assert.Equal(t, "request: fields error", entry["error"])
+		assert.Equal(t, "john", entry["err_user"])
+		assert.Equal(t, float64(3), entry["err_attempt"])
+	}
+
+	assert.NotContains(t, lines[2], "err_")
+}
+
+func TestWithCallStackDepth(t *testing.T) {
+	defer sink.Reset()
+
+	l := flash.New(flash.WithSinks("memory://"), flash.WithEncoder(flash.JSON), flash.WithCallStackDepth("depth"))
+
+	var nested func(n int)
+	nested = func(n int) {
+		if n == 0 {
+			l.Info("nested")
+			return
+		}
+
+		nested(n - 1)
+	}
+
+	nested(0)
+	nested(3)
+
+	lines := strings.Split(strings.TrimSpace(sink.String()), "\n")
+	require.Len(t, lines, 2)
+
+	depths := make([]float64, 0, len(lines))
+
+	for _, line := range lines {
+		var entry map[string]interface{}
+
+		require.NoError(t, json.Unmarshal([]byte(line), &entry))
+
+		depth, ok := entry["depth"].(float64)
+		require.True(t, ok)
+
+		depths = append(depths, depth)
+	}
+
+	assert.Equal(t, depths[0]+3, depths[1])
+}
+
+func TestWithJSONArray(t *testing.T) {
+	dir, err := ioutil.TempDir("", "flash")
+	require.NoError(t, err)
+
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	path := dir + "/batch.json"
+	require.NoError(t, ioutil.WriteFile(path, []byte("{\"previous\":\"run\"}\n"), 0o600))
+
+	l := flash.New(flash.WithFile(flash.FileConfig{Path: path}), flash.WithJSONArray())
+
+	_, err = flash.NewE(flash.WithFile(flash.FileConfig{Path: path}), flash.WithJSONArray())
+	require.Error(t, err, "the file is already in use")
+
+	l.Info("first")
+	l.Infow("second", "key", "value")
+	l.Warn("third")
+	require.NoError(t, l.Close())
+
+	d, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+
+	var entries []logEntry
+
+	require.NoError(t, json.Unmarshal(d, &entries), string(d))
+	require.Len(t, entries, 3)
+	assert.Equal(t, "first", entries[0].Msg)
+	assert.Equal(t, "WARN", entries[2].Level)
+
+	t.Run("empty", func(t *testing.T) {
+		l := flash.New(flash.WithFile(flash.FileConfig{Path: path}), flash.WithJSONArray())
+		require.NoError(t, l.Close())
+
+		d, err := ioutil.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, "[]\n", string(d))
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		tt := []struct {
+			name string
+			opts []flash.Option
+		}{
+			{"console encoder", []flash.Option{flash.WithFile(flash.FileConfig{Path: path}), flash.WithJSONArray(), flash.WithEncoder(flash.Console)}},
+			{"no file", []flash.Option{flash.WithSinks("stderr"), flash.WithJSONArray()}},
+			{"rotated", []flash.Option{flash.WithFile(flash.FileConfig{Path: path, MaxSize: 1}), flash.WithJSONArray()}},
+		}
+
+		for _, tc := range tt {
+			_, err := flash.NewE(tc.opts...)
+			assert.Error(t, err, tc.name)
+		}
+	})
+}
+
+func TestWithOmitEmpty(t *testing.T) {
+	defer sink.Reset()
+
+	l := flash.New(flash.WithSinks("memory://"), flash.WithEncoder(flash.JSON), flash.WithOmitEmpty())
+	l.Infow("omit", "empty", "", "populated", "value", "count", 0)
+
+	var entry map[string]interface{}
+
+	require.NoError(t, json.Unmarshal([]byte(sink.String()), &entry))
+	assert.NotContains(t, entry, "empty")
+	assert.Equal(t, "value", entry["populated"])
+	assert.Equal(t, float64(0), entry["count"])
+
+	t.Run("zero", func(t *testing.T) {
+		sink.Reset()
+
+		l := flash.New(flash.WithSinks("memory://"), flash.WithEncoder(flash.JSON), flash.WithOmitZero())
+		l.Infow("omit", "empty", "", "populated", "value", "count", 0)
+
+		var entry map[string]interface{}
+
+		require.NoError(t, json.Unmarshal([]byte(sink.String()), &entry))
+		assert.NotContains(t, entry, "empty")
+		assert.NotContains(t, entry, "count")
+		assert.Equal(t, "value", entry["populated"])
+	})
+}
+
+func TestWithAlignedConsoleAndFile(t *testing.T) {
+	defer sink.Reset()
+
+	dir, err := ioutil.TempDir("", "flash")
+	require.NoError(t, err)
+
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	path := dir + "/app.log"
+	l := flash.New(flash.WithConsoleAndFile(flash.FileConfig{Path: path}), flash.WithSinks("memory://"),
+		flash.WithEncoder(flash.Console), flash.WithAlignedConsole())
+	l.Info("message")
+
+	assert.Contains(t, sink.String(), "\tINFO \t")
+
+	d, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+
+	e := logEntry{}
+	require.NoError(t, json.Unmarshal(d, &e))
+	assert.Equal(t, "INFO", e.Level)
+	assert.Regexp(t, `^flash/flash_test\.go:\d+$`, e.Caller, "file caller should not be padded")
+}
+
+func TestWithFileConflictingConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "flash")
+	require.NoError(t, err)
+
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	fc := flash.FileConfig{Path: dir + "/app.log", MaxSize: 1}
+
+	l := flash.New(flash.WithFile(fc))
+	defer l.Close()
+
+	_, err = flash.NewE(flash.WithFile(fc))
+	require.NoError(t, err)
+
+	fc.MaxSize = 2
+
+	_, err = flash.NewE(flash.WithFile(fc))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "different configuration")
+}
+
+func TestWithFileReconfigure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "flash")
+	require.NoError(t, err)
+
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	fc := flash.FileConfig{Path: dir + "/app.log", MaxSize: 1}
+
+	l1 := flash.New(flash.WithFile(fc))
+	l2 := flash.New(flash.WithFile(fc))
+
+	fc.MaxSize = 2
+
+	require.NoError(t, l1.Close())
+
+	_, err = flash.NewE(flash.WithFile(fc))
+	require.Error(t, err, "the file is still used by l2")
+
+	l2.Info("still open")
+	require.NoError(t, l2.Close())
+
+	l3, err := flash.NewE(flash.WithFile(fc))
+	require.NoError(t, err)
+	l3.Info("reconfigured")
+	require.NoError(t, l3.Close())
+
+	d, err := ioutil.ReadFile(fc.Path)
+	require.NoError(t, err)
+	assert.Contains(t, string(d), "still open")
+	assert.Contains(t, string(d), "reconfigured")
+}
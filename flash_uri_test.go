@@ -1,11 +1,14 @@
 package flash
 
 import (
+	"io/ioutil"
 	"net/url"
+	"os"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"gopkg.in/natefinch/lumberjack.v2"
 )
 
 func TestURI(t *testing.T) {
@@ -48,3 +51,75 @@ func TestURI(t *testing.T) {
 		assert.Equal(t, tc.fileConfig.Path, p)
 	}
 }
+
+type countingFile struct {
+	*os.File
+	syncs *int
+}
+
+func (f countingFile) Sync() error {
+	*f.syncs++
+	return f.File.Sync()
+}
+
+func TestSyncEachLine(t *testing.T) {
+	dir, err := ioutil.TempDir("", "flash")
+	require.NoError(t, err)
+
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	var opened, synced int
+
+	s := lumberjackSink{
+		Logger: &lumberjack.Logger{Filename: dir + "/app.log"},
+		syncer: &fileSyncer{
+			open: func(path string) (syncFile, error) {
+				opened++
+
+				f, err := os.OpenFile(path, os.O_WRONLY, 0)
+
+				return countingFile{File: f, syncs: &synced}, err
+			},
+		},
+	}
+
+	for i := 0; i < 3; i++ {
+		_, err := s.Write([]byte("line\n"))
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, 3, synced)
+	assert.Equal(t, 1, opened, "the handle is kept")
+
+	require.NoError(t, s.Rotate())
+
+	_, err = s.Write([]byte("rotated\n"))
+	require.NoError(t, err)
+
+	assert.Equal(t, 4, synced)
+	assert.Equal(t, 2, opened, "the rotated file is opened")
+
+	d, err := ioutil.ReadFile(dir + "/app.log")
+	require.NoError(t, err)
+	assert.Equal(t, "rotated\n", string(d))
+
+	require.NoError(t, s.syncer.close())
+	require.NoError(t, s.Close())
+
+	t.Run("configured", func(t *testing.T) {
+		for _, syncEachLine := range []bool{true, false} {
+			fc := FileConfig{Path: dir + "/configured.log", SyncEachLine: syncEachLine}
+			require.NoError(t, fileSinks.register(fc))
+
+			u, err := url.Parse(fc.sinkURI())
+			require.NoError(t, err)
+
+			sink, err := fileSinks.open(u)
+			require.NoError(t, err)
+			assert.Equal(t, syncEachLine, sink.(*fileSinkRef).syncer != nil)
+			require.NoError(t, sink.Close())
+		}
+	})
+}
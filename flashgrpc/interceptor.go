@@ -0,0 +1,63 @@
+// Package flashgrpc provides gRPC server interceptors storing request-scoped flash loggers
+// in the context of each call. It is a separate module, so that flash does not depend on
+// gRPC.
+package flashgrpc
+
+import (
+	"context"
+	"strings"
+
+	"github.com/postfinance/flash"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// UnaryServerInterceptor returns a gRPC interceptor, which stores a request-scoped logger
+// of l with a `correlation_id` field in the context of each call. The correlation ID is
+// taken from the x-correlation-id or x-request-id metadata, see flash.CorrelationID, and
+// sent back as x-correlation-id header. The logger has the fields of the context added by
+// Ctx. Handlers get the logger with flash.FromContext.
+func UnaryServerInterceptor(l *flash.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		id := flash.CorrelationID(incomingMetadata(ctx))
+		_ = grpc.SetHeader(ctx, metadata.Pairs(flash.CorrelationIDHeader, id))
+
+		return handler(l.NewCorrelationContext(ctx, id), req)
+	}
+}
+
+// StreamServerInterceptor returns a gRPC stream interceptor, which stores a request-scoped
+// logger of l in the context of each stream like UnaryServerInterceptor.
+func StreamServerInterceptor(l *flash.Logger) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx := ss.Context()
+		id := flash.CorrelationID(incomingMetadata(ctx))
+		_ = ss.SetHeader(metadata.Pairs(flash.CorrelationIDHeader, id))
+
+		return handler(srv, &contextStream{ServerStream: ss, ctx: l.NewCorrelationContext(ctx, id)})
+	}
+}
+
+// incomingMetadata returns a function returning the first value of a key of the incoming
+// metadata of ctx.
+func incomingMetadata(ctx context.Context) func(key string) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	return func(key string) string {
+		if values := md.Get(strings.ToLower(key)); len(values) > 0 {
+			return values[0]
+		}
+
+		return ""
+	}
+}
+
+// contextStream is a grpc.ServerStream with the context of the request-scoped logger.
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,62 @@
+package flashgrpc_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/postfinance/flash"
+	"github.com/postfinance/flash/flashgrpc"
+	"github.com/postfinance/flash/flashtest"
+	"github.com/stretchr/testify/require"
+	"github.com/tj/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+type fakeServerStream struct {
+	grpc.ServerStream
+	ctx    context.Context
+	header metadata.MD
+}
+
+func (s *fakeServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *fakeServerStream) SetHeader(md metadata.MD) error {
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+func TestServerInterceptors(t *testing.T) {
+	l, entries := flashtest.CaptureT(t)
+
+	t.Run("unary", func(t *testing.T) {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-request-id", "incoming"))
+
+		_, err := flashgrpc.UnaryServerInterceptor(l)(ctx, nil, &grpc.UnaryServerInfo{}, func(ctx context.Context, _ interface{}) (interface{}, error) {
+			flash.FromContext(ctx).Info("handled")
+			return nil, nil
+		})
+		require.NoError(t, err)
+
+		e := entries()
+		assert.Equal(t, "incoming", e[len(e)-1].Fields["correlation_id"])
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		ss := &fakeServerStream{ctx: context.Background()}
+
+		err := flashgrpc.StreamServerInterceptor(l)(nil, ss, &grpc.StreamServerInfo{}, func(_ interface{}, ss grpc.ServerStream) error {
+			flash.FromContext(ss.Context()).Info("handled")
+			return nil
+		})
+		require.NoError(t, err)
+
+		e := entries()
+		id, ok := e[len(e)-1].Fields["correlation_id"].(string)
+		require.True(t, ok)
+		assert.Len(t, id, 32)
+		assert.Equal(t, []string{id}, ss.header.Get("x-correlation-id"))
+	})
+}
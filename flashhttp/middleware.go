@@ -0,0 +1,25 @@
+// Package flashhttp provides an HTTP middleware storing request-scoped flash loggers in the
+// request context.
+package flashhttp
+
+import (
+	"net/http"
+
+	"github.com/postfinance/flash"
+)
+
+// Middleware returns an HTTP middleware, which stores a request-scoped logger of l with a
+// `correlation_id` field in the context of each request. The correlation ID is taken from
+// the X-Correlation-ID or X-Request-ID request header; if both are missing or invalid, a
+// new one is generated, see flash.CorrelationID. The correlation ID is also set as
+// X-Correlation-ID response header. The logger has the fields of the request context added
+// by Ctx. Handlers get the logger with flash.FromContext. To log the members of the W3C
+// baggage request header, wrap the middleware with the one of the flashotel module.
+func Middleware(l *flash.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := flash.CorrelationID(r.Header.Get)
+		w.Header().Set(flash.CorrelationIDHeader, id)
+
+		next.ServeHTTP(w, r.WithContext(l.NewCorrelationContext(r.Context(), id)))
+	})
+}
@@ -0,0 +1,46 @@
+package flashhttp_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/postfinance/flash"
+	"github.com/postfinance/flash/flashhttp"
+	"github.com/postfinance/flash/flashtest"
+	"github.com/stretchr/testify/require"
+	"github.com/tj/assert"
+)
+
+func TestMiddleware(t *testing.T) {
+	l, entries := flashtest.CaptureT(t)
+
+	h := flashhttp.Middleware(l, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flash.FromContext(r.Context()).Info("handled")
+	}))
+
+	t.Run("incoming header", func(t *testing.T) {
+		for _, header := range []string{flash.CorrelationIDHeader, flash.RequestIDHeader} {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set(header, "incoming-"+header)
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			e := entries()
+			assert.Equal(t, "incoming-"+header, e[len(e)-1].Fields["correlation_id"])
+			assert.Equal(t, "incoming-"+header, w.Header().Get(flash.CorrelationIDHeader))
+		}
+	})
+
+	t.Run("generated", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		e := entries()
+		id, ok := e[len(e)-1].Fields["correlation_id"].(string)
+		require.True(t, ok)
+		assert.Len(t, id, 32)
+		assert.Equal(t, id, w.Header().Get(flash.CorrelationIDHeader))
+	})
+}
@@ -0,0 +1,65 @@
+// Package flashotel adds members of the OpenTelemetry baggage to flash loggers. It is a
+// separate module, so that flash does not depend on OpenTelemetry.
+package flashotel
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/postfinance/flash"
+	"go.opentelemetry.io/otel/baggage"
+)
+
+// BaggageHeader is the HTTP header carrying W3C baggage, as propagated by OpenTelemetry.
+const BaggageHeader = "baggage"
+
+// WithBaggageKeys configures the members of the OpenTelemetry baggage, which are added as
+// fields by flash.Logger.Ctx, for example the baggage extracted by an OpenTelemetry
+// propagator. Members of the baggage, which are not configured, are not logged. Absent
+// baggage or members, no fields are added.
+func WithBaggageKeys(keys ...string) flash.Option {
+	return flash.WithContextFields(func(ctx context.Context) []interface{} {
+		return baggageFields(ctx, keys)
+	})
+}
+
+// baggageFields returns the baggage members of ctx with keys as key-value pairs.
+func baggageFields(ctx context.Context, keys []string) []interface{} {
+	b := baggage.FromContext(ctx)
+	if b.Len() == 0 {
+		return nil
+	}
+
+	fields := make([]interface{}, 0, 2*len(keys))
+
+	for _, key := range keys {
+		m := b.Member(key)
+		if m.Key() == "" {
+			continue
+		}
+
+		fields = append(fields, key, m.Value())
+	}
+
+	return fields
+}
+
+// Middleware returns an HTTP middleware, which stores the W3C baggage of the baggage
+// request header in the request context, if the context has no OpenTelemetry baggage yet.
+// It is used before the middleware of the flashhttp package, so that the request-scoped
+// logger has the members configured WithBaggageKeys as fields.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if baggage.FromContext(ctx).Len() > 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if b, err := baggage.Parse(r.Header.Get(BaggageHeader)); err == nil {
+			r = r.WithContext(baggage.ContextWithBaggage(ctx, b))
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
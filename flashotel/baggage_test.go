@@ -0,0 +1,64 @@
+package flashotel_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/postfinance/flash"
+	"github.com/postfinance/flash/flashhttp"
+	"github.com/postfinance/flash/flashotel"
+	"github.com/postfinance/flash/flashtest"
+	"github.com/stretchr/testify/require"
+	"github.com/tj/assert"
+	"go.opentelemetry.io/otel/baggage"
+)
+
+func TestWithBaggageKeys(t *testing.T) {
+	b, err := baggage.Parse("tenant=acme,region=eu%20west;ttl=1,secret=xyz")
+	require.NoError(t, err)
+
+	ctx := baggage.ContextWithBaggage(context.Background(), b)
+
+	l, entries := flashtest.CaptureT(t, flashotel.WithBaggageKeys("tenant", "region", "missing"))
+	l.Ctx(ctx).Info("with baggage")
+
+	e := entries()
+	require.Len(t, e, 1)
+	assert.Equal(t, map[string]interface{}{"tenant": "acme", "region": "eu west"}, e[0].Fields)
+
+	t.Run("without baggage", func(t *testing.T) {
+		l.Ctx(context.Background()).Info("without baggage")
+
+		e := entries()
+		require.Len(t, e, 2)
+		assert.Empty(t, e[1].Fields)
+	})
+}
+
+func TestMiddleware(t *testing.T) {
+	l, entries := flashtest.CaptureT(t, flashotel.WithBaggageKeys("tenant", "region"))
+
+	h := flashotel.Middleware(flashhttp.Middleware(l, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flash.FromContext(r.Context()).Info("handled")
+	})))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set(flashotel.BaggageHeader, "tenant=acme,secret=xyz")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	e := entries()
+	assert.Equal(t, "acme", e[len(e)-1].Fields["tenant"])
+	assert.NotContains(t, e[len(e)-1].Fields, "secret")
+
+	// baggage set by an OpenTelemetry middleware takes precedence over the header
+	b, err := baggage.Parse("region=eu%20west")
+	require.NoError(t, err)
+
+	h.ServeHTTP(httptest.NewRecorder(), r.WithContext(baggage.ContextWithBaggage(r.Context(), b)))
+
+	e = entries()
+	assert.Equal(t, "eu west", e[len(e)-1].Fields["region"])
+	assert.NotContains(t, e[len(e)-1].Fields, "tenant")
+}
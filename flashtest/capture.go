@@ -0,0 +1,125 @@
+// Package flashtest provides helpers to assert the entries of flash loggers in tests.
+package flashtest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/postfinance/flash"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// Entry is a log entry captured by CaptureT.
+type Entry struct {
+	Level      zapcore.Level
+	Logger     string
+	Caller     string
+	Message    string
+	Stacktrace string
+	// Fields contains all other keys of the entry, except the timestamp.
+	Fields map[string]interface{}
+}
+
+// CaptureT returns a logger, which logs with the JSON encoder into memory, and a function
+// returning the entries logged so far. The logger is synced, when the test finishes. Each
+// logger has its own memory, so it can be used in parallel tests. Options changing the
+// encoder or the keys of entries are overridden.
+func CaptureT(t testing.TB, opts ...flash.Option) (*flash.Logger, func() []Entry) {
+	t.Helper()
+
+	buf := &captureBuffer{}
+
+	opts = append(opts, flash.WithEncoder(flash.JSON), flash.WithKeys(flash.Keys{}), flash.WithOutput(buf))
+
+	l, err := flash.NewE(opts...)
+	if err != nil {
+		t.Fatalf("could not create logger: %v", err)
+	}
+
+	t.Cleanup(func() {
+		_ = l.Sync()
+	})
+
+	return l, func() []Entry {
+		entries, err := buf.entries()
+		if err != nil {
+			t.Fatalf("could not parse captured entries: %v", err)
+		}
+
+		return entries
+	}
+}
+
+// WithObserver returns an option, which additionally sends all entries to a zap observer,
+// and the observed entries. Unlike CaptureT, the configured encoder and sinks are still
+// used, so tests can assert the structured entries and the formatted output.
+func WithObserver() (flash.Option, *observer.ObservedLogs) {
+	core, logs := observer.New(zapcore.DebugLevel)
+
+	return flash.WithTee(core), logs
+}
+
+// captureBuffer is a concurrency safe zapcore.WriteSyncer writing into memory.
+type captureBuffer struct {
+	m   sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *captureBuffer) Write(p []byte) (int, error) {
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	return b.buf.Write(p)
+}
+
+func (b *captureBuffer) Sync() error {
+	return nil
+}
+
+// entries parses the JSON lines written so far.
+func (b *captureBuffer) entries() ([]Entry, error) {
+	b.m.Lock()
+	data := append([]byte(nil), b.buf.Bytes()...)
+	b.m.Unlock()
+
+	var entries []Entry
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, len(data)+1)
+
+	for scanner.Scan() {
+		fields := map[string]interface{}{}
+		if err := json.Unmarshal(scanner.Bytes(), &fields); err != nil {
+			return nil, err
+		}
+
+		e := Entry{Fields: fields}
+
+		if s, ok := fields["level"].(string); ok {
+			if err := e.Level.UnmarshalText([]byte(s)); err != nil {
+				return nil, err
+			}
+		}
+
+		for key, dst := range map[string]*string{
+			"logger":     &e.Logger,
+			"caller":     &e.Caller,
+			"msg":        &e.Message,
+			"stacktrace": &e.Stacktrace,
+		} {
+			*dst, _ = fields[key].(string)
+		}
+
+		for _, key := range []string{"ts", "level", "logger", "caller", "msg", "stacktrace"} {
+			delete(fields, key)
+		}
+
+		entries = append(entries, e)
+	}
+
+	return entries, scanner.Err()
+}
@@ -0,0 +1,67 @@
+package flashtest_test
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/postfinance/flash"
+	"github.com/postfinance/flash/flashtest"
+	"github.com/stretchr/testify/require"
+	"github.com/tj/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestCaptureT(t *testing.T) {
+	for i := 0; i < 2; i++ {
+		name := fmt.Sprintf("logger-%d", i)
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			l, entries := flashtest.CaptureT(t, flash.WithDebug(true))
+
+			for j := 0; j < 10; j++ {
+				l.Named(name).Debugw("captured", "n", j)
+			}
+
+			l.Error("error")
+
+			e := entries()
+			require.Len(t, e, 11)
+
+			for j, entry := range e[:10] {
+				assert.Equal(t, zap.DebugLevel, entry.Level)
+				assert.Equal(t, name, entry.Logger)
+				assert.Equal(t, "captured", entry.Message)
+				assert.Equal(t, map[string]interface{}{"n": float64(j)}, entry.Fields)
+				assert.Contains(t, entry.Caller, "flashtest/capture_test.go")
+			}
+
+			assert.Equal(t, zap.ErrorLevel, e[10].Level)
+		})
+	}
+}
+
+func TestWithObserver(t *testing.T) {
+	var buf bytes.Buffer
+
+	observe, logs := flashtest.WithObserver()
+
+	l := flash.New(flash.WithOutput(zapcore.AddSync(&buf)), flash.WithEncoder(flash.Console), observe)
+	l.Infow("observed", "key", "value")
+	l.Debug("debug")
+	l.Errorw("failed", "attempt", 2)
+
+	entries := logs.All()
+	require.Len(t, entries, 2)
+	assert.Equal(t, zapcore.InfoLevel, entries[0].Level)
+	assert.Equal(t, "observed", entries[0].Message)
+	assert.Equal(t, map[string]interface{}{"key": "value"}, entries[0].ContextMap())
+	assert.Equal(t, zapcore.ErrorLevel, entries[1].Level)
+	assert.Equal(t, map[string]interface{}{"attempt": int64(2)}, entries[1].ContextMap())
+
+	assert.Contains(t, buf.String(), "INFO\tflashtest/capture_test.go")
+	assert.Contains(t, buf.String(), "observed\t{\"key\": \"value\"}")
+}
@@ -0,0 +1,86 @@
+package flash
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// WithFlattenFields flattens the fields of nested objects and arrays into top-level fields
+// for the JSON encoder, for example `{"user":{"id":1}}` to `{"user.id":1}` with the separator
+// `.`. The keys of objects are sorted and array elements are flattened with their index as
+// key like `items.0`. It has no effect on other encoders.
+func WithFlattenFields(separator string) Option {
+	return func(c *config) {
+		c.flattenSeparator = &separator
+	}
+}
+
+// flattenFields is a transformer which flattens nested fields with the separator.
+type flattenFields string
+
+func (t flattenFields) with(fields []zapcore.Field) ([]zapcore.Field, transformer) {
+	return t.flatten(make([]zapcore.Field, 0, len(fields)), fields), t
+}
+
+func (t flattenFields) transform(e zapcore.Entry, dst, fields []zapcore.Field) (zapcore.Entry, []zapcore.Field) {
+	return e, t.flatten(dst, fields)
+}
+
+func (t flattenFields) flatten(dst, fields []zapcore.Field) []zapcore.Field {
+	for _, f := range fields {
+		switch f.Type { // nolint: exhaustive
+		case zapcore.ObjectMarshalerType, zapcore.ArrayMarshalerType:
+			enc := zapcore.NewMapObjectEncoder()
+			f.AddTo(enc)
+
+			dst = t.appendValue(dst, f.Key, enc.Fields[f.Key])
+		case zapcore.ReflectType:
+			b, err := json.Marshal(f.Interface)
+			if err != nil {
+				dst = append(dst, f)
+				continue
+			}
+
+			var v interface{}
+			if err := json.Unmarshal(b, &v); err != nil {
+				dst = append(dst, f)
+				continue
+			}
+
+			dst = t.appendValue(dst, f.Key, v)
+		default:
+			dst = append(dst, f)
+		}
+	}
+
+	return dst
+}
+
+// appendValue appends v as fields with key as prefix.
+func (t flattenFields) appendValue(dst []zapcore.Field, key string, v interface{}) []zapcore.Field {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			dst = t.appendValue(dst, key+string(t)+k, v[k])
+		}
+	case []interface{}:
+		for i := range v {
+			dst = t.appendValue(dst, key+string(t)+strconv.Itoa(i), v[i])
+		}
+	default:
+		dst = append(dst, zap.Any(key, v))
+	}
+
+	return dst
+}
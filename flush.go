@@ -0,0 +1,47 @@
+package flash
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// WithBackgroundFlush syncs the logger every interval until ctx is done or the logger is
+// closed, so that entries of buffered sinks are written without explicit calls of Sync.
+func WithBackgroundFlush(ctx context.Context, interval time.Duration) Option {
+	return func(c *config) {
+		c.flushCtx = ctx
+		c.flushInterval = interval
+	}
+}
+
+// flush syncs l every interval until ctx is done or the returned function is called. The
+// base logger is synced, because the sugared logger is replaced on stacktrace level
+// changes. The returned function waits for a running sync, so that the sinks are not
+// synced after they are closed.
+func flush(ctx context.Context, interval time.Duration, l *zap.Logger) func() {
+	ctx, cancel := context.WithCancel(ctx)
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				_ = l.Sync()
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-stopped
+	}
+}
@@ -0,0 +1,100 @@
+package flash_test
+
+import (
+	"bytes"
+	"context"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/postfinance/flash"
+	"github.com/tj/assert"
+	"go.uber.org/zap"
+)
+
+// bufferedSink is a zap.Sink, which keeps writes pending until Sync.
+type bufferedSink struct {
+	m       sync.Mutex
+	pending bytes.Buffer
+	flushed bytes.Buffer
+	syncs   int
+}
+
+func (s *bufferedSink) Write(p []byte) (int, error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	return s.pending.Write(p)
+}
+
+func (s *bufferedSink) Sync() error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.syncs++
+	_, err := s.pending.WriteTo(&s.flushed)
+
+	return err
+}
+
+func (s *bufferedSink) Close() error { return nil }
+
+func (s *bufferedSink) state() (flushed string, syncs int) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	return s.flushed.String(), s.syncs
+}
+
+func TestWithBackgroundFlush(t *testing.T) {
+	buffered := &bufferedSink{}
+
+	_ = zap.RegisterSink("buffered", func(*url.URL) (zap.Sink, error) {
+		return buffered, nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	l := flash.New(flash.WithSinks("buffered://"), flash.WithoutInitialSync(),
+		flash.WithBackgroundFlush(ctx, 10*time.Millisecond))
+	l.Info("flushed in background")
+
+	assert.Eventually(t, func() bool {
+		flushed, _ := buffered.state()
+		return strings.Contains(flushed, "flushed in background")
+	}, 5*time.Second, 5*time.Millisecond)
+
+	cancel()
+	time.Sleep(50 * time.Millisecond)
+
+	_, syncs := buffered.state()
+
+	time.Sleep(50 * time.Millisecond)
+
+	_, after := buffered.state()
+	assert.Equal(t, syncs, after)
+
+	t.Run("stopped by close", func(t *testing.T) {
+		l := flash.New(flash.WithSinks("buffered://"), flash.WithoutInitialSync(),
+			flash.WithBackgroundFlush(context.Background(), time.Millisecond))
+
+		_, before := buffered.state()
+
+		assert.Eventually(t, func() bool {
+			_, syncs := buffered.state()
+			return syncs > before
+		}, 5*time.Second, time.Millisecond)
+
+		assert.NoError(t, l.Close())
+
+		_, syncs := buffered.state()
+
+		time.Sleep(50 * time.Millisecond)
+
+		_, after := buffered.state()
+		assert.Equal(t, syncs, after)
+	})
+}
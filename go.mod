@@ -3,11 +3,14 @@ module github.com/postfinance/flash
 go 1.15
 
 require (
+	github.com/fsnotify/fsnotify v1.4.9
 	github.com/mattn/go-isatty v0.0.18
 	github.com/prometheus/client_golang v1.15.0
 	github.com/stretchr/testify v1.8.2
 	github.com/sykesm/zap-logfmt v0.0.4
 	github.com/tj/assert v0.0.3
+	go.uber.org/multierr v1.6.0
 	go.uber.org/zap v1.24.0
+	golang.org/x/sys v0.6.0
 	gopkg.in/natefinch/lumberjack.v2 v2.2.1
 )
@@ -0,0 +1,58 @@
+package flash
+
+import (
+	"bytes"
+	"runtime"
+	"strconv"
+	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// WithGoroutineID annotates logs with the ID of the logging goroutine in a `goroutine`
+// field, for example to debug concurrency issues. The ID is parsed from the stack trace
+// of the goroutine for every entry, which costs about a microsecond, so the option is
+// meant for debugging only. The ID cannot be cached, because Go has no goroutine local
+// storage and a logger is usually shared between goroutines.
+func WithGoroutineID() Option {
+	return func(c *config) {
+		c.goroutineID = true
+	}
+}
+
+// nolint: gochecknoglobals
+var stackPool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, 64)
+		return &b
+	},
+}
+
+// goroutineID returns the ID of the current goroutine parsed from the first line of its
+// stack trace, which looks like `goroutine 42 [running]:`.
+func goroutineID() uint64 {
+	p := stackPool.Get().(*[]byte)
+	defer stackPool.Put(p)
+
+	b := (*p)[:runtime.Stack(*p, false)]
+	b = bytes.TrimPrefix(b, []byte("goroutine "))
+
+	if i := bytes.IndexByte(b, ' '); i >= 0 {
+		b = b[:i]
+	}
+
+	id, _ := strconv.ParseUint(string(b), 10, 64)
+
+	return id
+}
+
+// goroutineField is a transformer which adds the ID of the logging goroutine to every
+// entry.
+type goroutineField struct{}
+
+func (goroutineField) transform(e zapcore.Entry, dst, fields []zapcore.Field) (zapcore.Entry, []zapcore.Field) {
+	dst = append(dst, zap.Uint64("goroutine", goroutineID()))
+
+	return e, append(dst, fields...)
+}
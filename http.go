@@ -0,0 +1,78 @@
+package flash
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const redacted = "[REDACTED]"
+
+// nolint: gochecknoglobals
+var redactedHeaders = map[string]struct{}{
+	"Authorization":       {},
+	"Cookie":              {},
+	"Proxy-Authorization": {},
+	"Set-Cookie":          {},
+}
+
+// RequestField returns a field `request` with the method, URL, headers and body size of r.
+// The values of sensitive headers like Authorization and Cookie are redacted.
+func RequestField(r *http.Request) zap.Field {
+	return zap.Object("request", zapcore.ObjectMarshalerFunc(func(enc zapcore.ObjectEncoder) error {
+		if r == nil {
+			return nil
+		}
+
+		enc.AddString("method", r.Method)
+
+		if r.URL != nil {
+			enc.AddString("url", r.URL.String())
+		}
+
+		enc.AddInt64("body_size", r.ContentLength)
+
+		return enc.AddObject("headers", headers(r.Header))
+	}))
+}
+
+// ResponseField returns a field `response` with the status code, headers and body size
+// of resp. The values of sensitive headers like Set-Cookie are redacted.
+func ResponseField(resp *http.Response) zap.Field {
+	return zap.Object("response", zapcore.ObjectMarshalerFunc(func(enc zapcore.ObjectEncoder) error {
+		if resp == nil {
+			return nil
+		}
+
+		enc.AddInt("status", resp.StatusCode)
+		enc.AddInt64("body_size", resp.ContentLength)
+
+		return enc.AddObject("headers", headers(resp.Header))
+	}))
+}
+
+// headers marshals h with sorted keys. Sensitive header values are redacted.
+type headers http.Header
+
+func (h headers) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if _, ok := redactedHeaders[http.CanonicalHeaderKey(k)]; ok {
+			enc.AddString(k, redacted)
+			continue
+		}
+
+		enc.AddString(k, strings.Join(h[k], ", "))
+	}
+
+	return nil
+}
@@ -0,0 +1,33 @@
+package flash
+
+import (
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Interface contains the commonly used methods of Logger. Depend on it instead of
+// *Logger to replace the logger in tests.
+type Interface interface {
+	Debug(args ...interface{})
+	Info(args ...interface{})
+	Warn(args ...interface{})
+	Error(args ...interface{})
+
+	Debugf(template string, args ...interface{})
+	Infof(template string, args ...interface{})
+	Warnf(template string, args ...interface{})
+	Errorf(template string, args ...interface{})
+
+	Debugw(msg string, keysAndValues ...interface{})
+	Infow(msg string, keysAndValues ...interface{})
+	Warnw(msg string, keysAndValues ...interface{})
+	Errorw(msg string, keysAndValues ...interface{})
+
+	With(args ...interface{}) *zap.SugaredLogger
+
+	SetLevel(level zapcore.Level)
+	SetDebug(d bool)
+	Disable()
+}
+
+var _ Interface = (*Logger)(nil)
@@ -0,0 +1,39 @@
+package flash_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/postfinance/flash"
+	"github.com/tj/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// mockLogger records the messages logged at InfoLevel.
+type mockLogger struct {
+	flash.Interface
+	infos []string
+}
+
+func (m *mockLogger) Infof(template string, args ...interface{}) {
+	m.infos = append(m.infos, fmt.Sprintf(template, args...))
+}
+
+func (m *mockLogger) SetLevel(zapcore.Level) {}
+
+func greet(l flash.Interface, name string) {
+	l.SetLevel(zap.InfoLevel)
+	l.Infof("hello %s", name)
+}
+
+func TestInterface(t *testing.T) {
+	m := &mockLogger{}
+	greet(m, "flash")
+	assert.Equal(t, []string{"hello flash"}, m.infos)
+
+	defer sink.Reset()
+
+	greet(flash.New(flash.WithSinks("memory://")), "flash")
+	assert.Contains(t, sink.String(), "hello flash")
+}
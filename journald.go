@@ -0,0 +1,265 @@
+package flash
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap/zapcore"
+)
+
+const journaldSocket = "/run/systemd/journal/socket"
+
+// nolint: gochecknoglobals
+var journaldPriorities = map[zapcore.Level]int{
+	zapcore.DebugLevel:  7,
+	zapcore.InfoLevel:   6,
+	zapcore.WarnLevel:   4,
+	zapcore.ErrorLevel:  3,
+	zapcore.DPanicLevel: 2,
+	zapcore.PanicLevel:  2,
+	zapcore.FatalLevel:  2,
+}
+
+type journaldConfig struct {
+	socket string
+	fields map[string]string
+}
+
+// WithJournald additionally sends all entries with the native protocol to the systemd
+// journal. The level is sent as syslog PRIORITY, the message as MESSAGE and fields are sent
+// with uppercased keys. Keys of logged fields which collide with the fields set by flash,
+// like MESSAGE, PRIORITY, SYSLOG_IDENTIFIER, CODE_FILE, CODE_LINE and STACKTRACE, are
+// prefixed with `F_`.
+//
+// The given fields are added to every entry. They can set well-known journal fields, for
+// example SYSLOG_IDENTIFIER, which takes precedence over the logger name. Only the fields
+// set per entry, like MESSAGE and PRIORITY, are prefixed with `F_`. Entries too large for
+// a datagram are passed to the journal in a sealed memory file on Linux. If the journal
+// socket cannot be opened, NewE returns an error.
+func WithJournald(fields map[string]string) Option {
+	return func(c *config) {
+		c.journald = &journaldConfig{
+			socket: journaldSocket,
+			fields: fields,
+		}
+	}
+}
+
+// journaldSocket returns the path of the journal socket, if entries are sent to the
+// journal.
+func (c config) journaldSocket() string {
+	if c.journald == nil {
+		return ""
+	}
+
+	return c.journald.socket
+}
+
+// nolint: gochecknoglobals
+var journaldReservedKeys = map[string]bool{
+	"PRIORITY":          true,
+	"MESSAGE":           true,
+	"SYSLOG_IDENTIFIER": true,
+	"CODE_FILE":         true,
+	"CODE_LINE":         true,
+	"STACKTRACE":        true,
+}
+
+// journaldCore is a zapcore.Core sending entries to the systemd journal.
+type journaldCore struct {
+	zapcore.LevelEnabler
+	conn       net.Conn
+	configured []journaldField
+	identifier bool
+	fields     []zapcore.Field
+}
+
+// journaldField is a field in the journal format.
+type journaldField struct {
+	key   string
+	value string
+}
+
+// newJournaldCore connects to the journal socket. It returns a function to close the
+// connection.
+func newJournaldCore(jc journaldConfig, level zapcore.LevelEnabler) (zapcore.Core, func(), error) {
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: jc.socket, Net: "unixgram"})
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not connect to journald: %w", err)
+	}
+
+	keys := make([]string, 0, len(jc.fields))
+	for k := range jc.fields {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	core := &journaldCore{
+		LevelEnabler: level,
+		conn:         conn,
+		configured:   make([]journaldField, 0, len(keys)),
+	}
+
+	for _, k := range keys {
+		key := journaldName(k)
+		if key == "SYSLOG_IDENTIFIER" {
+			core.identifier = true
+		} else if journaldReservedKeys[key] {
+			key = "F_" + key
+		}
+
+		core.configured = append(core.configured, journaldField{key: key, value: jc.fields[k]})
+	}
+
+	return core, func() { _ = conn.Close() }, nil
+}
+
+func (c *journaldCore) With(fields []zapcore.Field) zapcore.Core {
+	all := make([]zapcore.Field, 0, len(c.fields)+len(fields))
+	all = append(all, c.fields...)
+	all = append(all, fields...)
+
+	return &journaldCore{
+		LevelEnabler: c.LevelEnabler,
+		conn:         c.conn,
+		configured:   c.configured,
+		identifier:   c.identifier,
+		fields:       all,
+	}
+}
+
+func (c *journaldCore) Check(e zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(e.Level) {
+		return ce.AddCore(e, c)
+	}
+
+	return ce
+}
+
+// Write sends the entry as a single datagram, or as a file if it is too large.
+func (c *journaldCore) Write(e zapcore.Entry, fields []zapcore.Field) error {
+	var buf bytes.Buffer
+
+	writeJournaldField(&buf, "PRIORITY", strconv.Itoa(journaldPriorities[e.Level]))
+	writeJournaldField(&buf, "MESSAGE", e.Message)
+
+	if e.LoggerName != "" && !c.identifier {
+		writeJournaldField(&buf, "SYSLOG_IDENTIFIER", e.LoggerName)
+	}
+
+	if e.Caller.Defined {
+		writeJournaldField(&buf, "CODE_FILE", e.Caller.File)
+		writeJournaldField(&buf, "CODE_LINE", strconv.Itoa(e.Caller.Line))
+	}
+
+	if e.Stack != "" {
+		writeJournaldField(&buf, "STACKTRACE", e.Stack)
+	}
+
+	for _, f := range c.configured {
+		writeJournaldField(&buf, f.key, f.value)
+	}
+
+	enc := zapcore.NewMapObjectEncoder()
+
+	for i := range c.fields {
+		c.fields[i].AddTo(enc)
+	}
+
+	for i := range fields {
+		fields[i].AddTo(enc)
+	}
+
+	keys := make([]string, 0, len(enc.Fields))
+	for k := range enc.Fields {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		writeJournaldField(&buf, journaldKey(k), journaldValue(enc.Fields[k]))
+	}
+
+	return sendJournald(c.conn, buf.Bytes())
+}
+
+// Sync implements zapcore.Core. Datagrams are not buffered.
+func (c *journaldCore) Sync() error {
+	return nil
+}
+
+// writeJournaldField appends a field in the native journal format. Values containing
+// newlines are written in the binary format with an explicit length.
+func writeJournaldField(buf *bytes.Buffer, key, value string) {
+	buf.WriteString(key)
+
+	if !strings.ContainsRune(value, '\n') {
+		buf.WriteByte('=')
+		buf.WriteString(value)
+		buf.WriteByte('\n')
+
+		return
+	}
+
+	buf.WriteByte('\n')
+
+	_ = binary.Write(buf, binary.LittleEndian, uint64(len(value)))
+
+	buf.WriteString(value)
+	buf.WriteByte('\n')
+}
+
+// journaldKey converts the key of a logged field to a journal field name, which does not
+// collide with a reserved key.
+func journaldKey(key string) string {
+	key = journaldName(key)
+	if journaldReservedKeys[key] {
+		key = "F_" + key
+	}
+
+	return key
+}
+
+// journaldName converts key to a valid journal field name: uppercase letters, digits and
+// underscores, not starting with an underscore or a digit.
+func journaldName(key string) string {
+	key = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, key)
+
+	key = strings.TrimLeft(key, "_")
+	if key == "" || key[0] >= '0' && key[0] <= '9' {
+		key = "F_" + key
+	}
+
+	return key
+}
+
+// journaldValue formats strings as they are and all other values as JSON.
+func journaldValue(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+
+	return string(b)
+}
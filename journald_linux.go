@@ -0,0 +1,63 @@
+//go:build linux
+// +build linux
+
+package flash
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// sendJournald sends the entry b as a datagram. If the datagram is too large, b is
+// written to a sealed memory file, whose descriptor is sent to the journal instead,
+// like sd_journal_sendv does.
+func sendJournald(conn net.Conn, b []byte) error {
+	_, err := conn.Write(b)
+	if !errors.Is(err, syscall.EMSGSIZE) && !errors.Is(err, syscall.ENOBUFS) {
+		return err
+	}
+
+	uc, ok := conn.(*net.UnixConn)
+	if !ok {
+		return err
+	}
+
+	fd, err := unix.MemfdCreate("journal-entry", unix.MFD_ALLOW_SEALING|unix.MFD_CLOEXEC)
+	if err != nil {
+		return fmt.Errorf("could not create journal file: %w", err)
+	}
+
+	f := os.NewFile(uintptr(fd), "journal-entry")
+	defer f.Close()
+
+	if _, err := f.Write(b); err != nil {
+		return fmt.Errorf("could not write journal file: %w", err)
+	}
+
+	seals := unix.F_SEAL_SHRINK | unix.F_SEAL_GROW | unix.F_SEAL_WRITE | unix.F_SEAL_SEAL
+	if _, err := unix.FcntlInt(f.Fd(), unix.F_ADD_SEALS, seals); err != nil {
+		return fmt.Errorf("could not seal journal file: %w", err)
+	}
+
+	// net.UnixConn cannot send messages over a connected datagram socket
+	rc, err := uc.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var sendErr error
+
+	if err := rc.Write(func(s uintptr) bool {
+		sendErr = unix.Sendmsg(int(s), nil, unix.UnixRights(int(f.Fd())), nil, 0)
+		return !errors.Is(sendErr, unix.EAGAIN)
+	}); err != nil {
+		return err
+	}
+
+	return sendErr
+}
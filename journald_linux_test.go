@@ -0,0 +1,67 @@
+//go:build linux
+// +build linux
+
+package flash
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tj/assert"
+	"golang.org/x/sys/unix"
+)
+
+func TestJournaldLargeEntry(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "journal.sock")
+
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	require.NoError(t, err)
+
+	defer conn.Close()
+
+	l, err := NewE(
+		WithSinks(os.DevNull),
+		WithoutInitialSync(),
+		WithJournald(nil),
+		func(c *config) { c.journald.socket = socket },
+	)
+	require.NoError(t, err)
+
+	defer l.Close()
+
+	msg := strings.Repeat("x", 1<<20)
+	l.Info(msg)
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+
+	oob := make([]byte, unix.CmsgSpace(4))
+	n, oobn, _, _, err := conn.ReadMsgUnix(make([]byte, 16), oob)
+	require.NoError(t, err)
+	assert.Zero(t, n, "entry should be sent as file")
+
+	msgs, err := unix.ParseSocketControlMessage(oob[:oobn])
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+
+	fds, err := unix.ParseUnixRights(&msgs[0])
+	require.NoError(t, err)
+	require.Len(t, fds, 1)
+
+	f := os.NewFile(uintptr(fds[0]), "journal-entry")
+	defer f.Close()
+
+	// the file shares its offset with the sender, which is at the end of the entry
+	_, err = f.Seek(0, io.SeekStart)
+	require.NoError(t, err)
+
+	d, err := ioutil.ReadAll(f)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(d), "PRIORITY=6\nMESSAGE="+msg+"\n"))
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+// +build !linux
+
+package flash
+
+import "net"
+
+// sendJournald sends the entry b as a datagram. The journal is only available on Linux.
+func sendJournald(conn net.Conn, b []byte) error {
+	_, err := conn.Write(b)
+
+	return err
+}
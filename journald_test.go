@@ -0,0 +1,108 @@
+package flash
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tj/assert"
+)
+
+func TestWithJournald(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "journal.sock")
+
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	require.NoError(t, err)
+
+	defer conn.Close()
+
+	withSocket := func(c *config) {
+		c.journald.socket = socket
+	}
+
+	l, err := NewE(
+		WithSinks(os.DevNull),
+		WithoutInitialSync(),
+		WithJournald(map[string]string{"app": "flash"}),
+		withSocket,
+	)
+	require.NoError(t, err)
+
+	defer l.Close()
+
+	l.Warnw("multi\nline", "request-id", 42, "message", "field")
+
+	buf := make([]byte, 4096)
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+
+	n, err := conn.Read(buf)
+	require.NoError(t, err)
+
+	msg := string(buf[:n])
+	assert.True(t, strings.HasPrefix(msg, "PRIORITY=4\nMESSAGE\n"), msg)
+	assert.Contains(t, msg, "multi\nline\n")
+	assert.Contains(t, msg, "APP=flash\n")
+	assert.Contains(t, msg, "REQUEST_ID=42\n")
+	assert.Contains(t, msg, "F_MESSAGE=field\n")
+
+	t.Run("configured well-known fields", func(t *testing.T) {
+		l, err := NewE(
+			WithSinks(os.DevNull),
+			WithoutInitialSync(),
+			WithJournald(map[string]string{"SYSLOG_IDENTIFIER": "app", "MESSAGE": "configured"}),
+			withSocket,
+		)
+		require.NoError(t, err)
+
+		defer l.Close()
+
+		l.Named("component").Infow("info", "syslog_identifier", "field")
+
+		n, err := conn.Read(buf)
+		require.NoError(t, err)
+
+		msg := string(buf[:n])
+		assert.Equal(t, 1, strings.Count(msg, "SYSLOG_IDENTIFIER=app\n"), msg)
+		assert.NotContains(t, msg, "SYSLOG_IDENTIFIER=component")
+		assert.Contains(t, msg, "F_SYSLOG_IDENTIFIER=field\n")
+		assert.Contains(t, msg, "F_MESSAGE=configured\n")
+	})
+
+	t.Run("without journal", func(t *testing.T) {
+		_, err := NewE(
+			WithSinks(os.DevNull),
+			WithJournald(nil),
+			func(c *config) { c.journald.socket = filepath.Join(t.TempDir(), "missing.sock") },
+		)
+		assert.Error(t, err)
+	})
+
+	t.Run("validate", func(t *testing.T) {
+		socket := filepath.Join(t.TempDir(), "validate.sock")
+
+		conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+		require.NoError(t, err)
+
+		l, err := NewE(
+			WithSinks(os.DevNull),
+			WithJournald(nil),
+			func(c *config) { c.journald.socket = socket },
+		)
+		require.NoError(t, err)
+
+		defer l.Close()
+
+		require.NoError(t, l.ValidateSinks())
+
+		require.NoError(t, conn.Close())
+
+		err = l.ValidateSinks()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), socket)
+	})
+}
@@ -0,0 +1,127 @@
+package flash
+
+import (
+	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// LazyAt returns a field which adds the fields returned by fn to an entry, if the
+// entry level is at or above level and the logger is enabled at level. Otherwise
+// fn is not called and no fields are added.
+//
+//	l.Infow("request", flash.LazyAt(zapcore.DebugLevel, func() []zap.Field {
+//		return []zap.Field{zap.Any("headers", r.Header)}
+//	}))
+func LazyAt(level zapcore.Level, fn func() []zap.Field) zap.Field {
+	return zap.Inline(lazyFields{
+		level: level,
+		fn:    fn,
+	})
+}
+
+type lazyFields struct {
+	level zapcore.Level
+	fn    func() []zap.Field
+}
+
+// MarshalLogObject adds all fields. It is only used, if the lazy fields are
+// not resolved by the flash core.
+func (lf lazyFields) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	for _, f := range lf.fn() {
+		f.AddTo(enc)
+	}
+
+	return nil
+}
+
+func asLazyFields(f zapcore.Field) (lazyFields, bool) {
+	if f.Type != zapcore.InlineMarshalerType {
+		return lazyFields{}, false
+	}
+
+	lf, ok := f.Interface.(lazyFields)
+
+	return lf, ok
+}
+
+// lazyResolver is a transformer which resolves fields created with LazyAt, if the level
+// is enabled.
+type lazyResolver struct {
+	level zapcore.LevelEnabler
+	lazy  []lazyFields
+}
+
+func (t *lazyResolver) with(fields []zapcore.Field) ([]zapcore.Field, transformer) {
+	clone := &lazyResolver{
+		level: t.level,
+		lazy:  t.lazy,
+	}
+
+	other := make([]zapcore.Field, 0, len(fields))
+
+	for i := range fields {
+		if lf, ok := asLazyFields(fields[i]); ok {
+			clone.lazy = append(clone.lazy[:len(clone.lazy):len(clone.lazy)], lf)
+			continue
+		}
+
+		other = append(other, fields[i])
+	}
+
+	return other, clone
+}
+
+func (t *lazyResolver) transform(e zapcore.Entry, dst, fields []zapcore.Field) (zapcore.Entry, []zapcore.Field) {
+	for _, lf := range t.lazy {
+		dst = t.resolve(dst, e, lf)
+	}
+
+	for i := range fields {
+		if lf, ok := asLazyFields(fields[i]); ok {
+			dst = t.resolve(dst, e, lf)
+			continue
+		}
+
+		dst = append(dst, fields[i])
+	}
+
+	return e, dst
+}
+
+func (t *lazyResolver) resolve(dst []zapcore.Field, e zapcore.Entry, lf lazyFields) []zapcore.Field {
+	if e.Level < lf.level || !t.level.Enabled(lf.level) {
+		return dst
+	}
+
+	return append(dst, lf.fn()...)
+}
+
+// deferredField is a field with a value computed on first use. Concurrent log calls
+// block until the computation of the first call returns.
+type deferredField struct {
+	key   string
+	fn    func() interface{}
+	once  sync.Once
+	value interface{}
+}
+
+func (f *deferredField) field() zapcore.Field {
+	f.once.Do(func() {
+		f.value = f.fn()
+	})
+
+	return zap.Any(f.key, f.value)
+}
+
+// deferredFields is a transformer which adds deferred fields to every entry.
+type deferredFields []*deferredField
+
+func (t deferredFields) transform(e zapcore.Entry, dst, fields []zapcore.Field) (zapcore.Entry, []zapcore.Field) {
+	for _, f := range t {
+		dst = append(dst, f.field())
+	}
+
+	return e, append(dst, fields...)
+}
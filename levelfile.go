@@ -0,0 +1,113 @@
+package flash
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/fsnotify/fsnotify"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// WithLevelFile sets the level of the logger to the level name in the file at path, for
+// example a mounted Kubernetes config map, and applies changes of the file with
+// SetLevelString. The directory of the file is watched until the logger is closed, so
+// that files replaced by renames or symbolic links, like the files of config maps, are
+// followed. If the file cannot be read or contains an invalid level when the logger is
+// created, or if the directory cannot be watched, NewE returns an error. Later errors are
+// logged.
+func WithLevelFile(path string) Option {
+	return func(c *config) {
+		c.levelFile = path
+	}
+}
+
+// SetLevelString sets the level of the logger like SetLevel to the level with the given
+// name, for example `debug` or `INFO`.
+func (l *Logger) SetLevelString(s string) error {
+	var level zapcore.Level
+
+	if err := level.UnmarshalText([]byte(strings.TrimSpace(s))); err != nil {
+		return err
+	}
+
+	l.SetLevel(level)
+
+	return nil
+}
+
+// readLevelFile sets the level to the content of the file at path. It returns the content.
+func (l *Logger) readLevelFile(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("could not read level file: %w", err)
+	}
+
+	if err := l.SetLevelString(string(data)); err != nil {
+		return "", fmt.Errorf("invalid level file %s: %w", path, err)
+	}
+
+	return string(data), nil
+}
+
+// watchLevelFile applies changes of the level file in the background. It returns a
+// function to stop watching.
+func (l *Logger) watchLevelFile(path, content string) (func(), error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("could not watch level file: %w", err)
+	}
+
+	// the file itself is not watched, because the watch would be lost, when the file is
+	// replaced
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		_ = watcher.Close()
+		return nil, fmt.Errorf("could not watch level file: %w", err)
+	}
+
+	done := make(chan struct{})
+
+	go func() {
+		var lastErr string
+
+		for {
+			select {
+			case _, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+
+				data, err := ioutil.ReadFile(path)
+
+				switch {
+				case err != nil:
+					if err.Error() != lastErr {
+						l.base.Error("could not read level file", zap.Error(err))
+						lastErr = err.Error()
+					}
+				case string(data) != content:
+					content, lastErr = string(data), ""
+
+					if err := l.SetLevelString(content); err != nil {
+						l.base.Error("invalid level in level file", zap.String("path", path), zap.Error(err))
+					}
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+
+				l.base.Error("could not watch level file", zap.String("path", path), zap.Error(err))
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		_ = watcher.Close()
+	}, nil
+}
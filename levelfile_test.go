@@ -0,0 +1,83 @@
+package flash
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tj/assert"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestWithLevelFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "flash")
+	require.NoError(t, err)
+
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	path := filepath.Join(dir, "level")
+	require.NoError(t, ioutil.WriteFile(path, []byte("warn\n"), 0o600))
+
+	l, err := NewE(WithSinks("stderr"), WithLevelFile(path))
+	require.NoError(t, err)
+
+	defer l.Close()
+
+	assert.False(t, l.Desugar().Core().Enabled(zapcore.InfoLevel))
+	assert.True(t, l.Desugar().Core().Enabled(zapcore.WarnLevel))
+
+	require.NoError(t, ioutil.WriteFile(path, []byte("debug"), 0o600))
+
+	assert.Eventually(t, func() bool {
+		return l.Desugar().Core().Enabled(zapcore.DebugLevel)
+	}, time.Second, 10*time.Millisecond)
+
+	t.Run("config map", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "flash")
+		require.NoError(t, err)
+
+		defer func() {
+			_ = os.RemoveAll(dir)
+		}()
+
+		// a config map is mounted as symbolic links to a data directory, which is
+		// replaced on updates
+		update := func(version, level string) {
+			require.NoError(t, os.Mkdir(filepath.Join(dir, version), 0o700))
+			require.NoError(t, ioutil.WriteFile(filepath.Join(dir, version, "level"), []byte(level), 0o600))
+			require.NoError(t, os.Symlink(version, filepath.Join(dir, "..data_tmp")))
+			require.NoError(t, os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data")))
+		}
+
+		update("v1", "error")
+		require.NoError(t, os.Symlink(filepath.Join("..data", "level"), filepath.Join(dir, "level")))
+
+		l, err := NewE(WithSinks("stderr"), WithLevelFile(filepath.Join(dir, "level")))
+		require.NoError(t, err)
+
+		defer l.Close()
+
+		assert.False(t, l.Desugar().Core().Enabled(zapcore.WarnLevel))
+
+		update("v2", "info")
+
+		assert.Eventually(t, func() bool {
+			return l.Desugar().Core().Enabled(zapcore.InfoLevel)
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		require.NoError(t, ioutil.WriteFile(path, []byte("verbose"), 0o600))
+
+		_, err := NewE(WithSinks("stderr"), WithLevelFile(path))
+		assert.Error(t, err)
+
+		_, err = NewE(WithSinks("stderr"), WithLevelFile(filepath.Join(dir, "missing")))
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,89 @@
+package flash
+
+import (
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Log logs args at level like the level methods of zap.SugaredLogger, for example Info
+// for `InfoLevel`. Unknown levels are logged at error level.
+func (l *Logger) Log(level zapcore.Level, args ...interface{}) {
+	s := l.callerSkipped()
+
+	switch level { // nolint: exhaustive
+	case zapcore.DebugLevel:
+		s.Debug(args...)
+	case zapcore.InfoLevel:
+		s.Info(args...)
+	case zapcore.WarnLevel:
+		s.Warn(args...)
+	case zapcore.DPanicLevel:
+		s.DPanic(args...)
+	case zapcore.PanicLevel:
+		s.Panic(args...)
+	case zapcore.FatalLevel:
+		s.Fatal(args...)
+	default:
+		s.Error(args...)
+	}
+}
+
+// Logf logs a formatted message at level like Infof or Errorf. Unknown levels are logged
+// at error level.
+func (l *Logger) Logf(level zapcore.Level, template string, args ...interface{}) {
+	s := l.callerSkipped()
+
+	switch level { // nolint: exhaustive
+	case zapcore.DebugLevel:
+		s.Debugf(template, args...)
+	case zapcore.InfoLevel:
+		s.Infof(template, args...)
+	case zapcore.WarnLevel:
+		s.Warnf(template, args...)
+	case zapcore.DPanicLevel:
+		s.DPanicf(template, args...)
+	case zapcore.PanicLevel:
+		s.Panicf(template, args...)
+	case zapcore.FatalLevel:
+		s.Fatalf(template, args...)
+	default:
+		s.Errorf(template, args...)
+	}
+}
+
+// Logw logs a message with key-value pairs at level like Infow or Errorw, for example with
+// a level depending on the status of a response. Unknown levels are logged at error level.
+func (l *Logger) Logw(level zapcore.Level, msg string, keysAndValues ...interface{}) {
+	s := l.callerSkipped()
+
+	switch level { // nolint: exhaustive
+	case zapcore.DebugLevel:
+		s.Debugw(msg, keysAndValues...)
+	case zapcore.InfoLevel:
+		s.Infow(msg, keysAndValues...)
+	case zapcore.WarnLevel:
+		s.Warnw(msg, keysAndValues...)
+	case zapcore.DPanicLevel:
+		s.DPanicw(msg, keysAndValues...)
+	case zapcore.PanicLevel:
+		s.Panicw(msg, keysAndValues...)
+	case zapcore.FatalLevel:
+		s.Fatalw(msg, keysAndValues...)
+	default:
+		s.Errorw(msg, keysAndValues...)
+	}
+}
+
+// callerSkipped returns the sugared logger annotating the caller of the calling method.
+func (l *Logger) callerSkipped() *zap.SugaredLogger {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	return l.skipped
+}
+
+// skipCaller returns s annotating the caller of the calling method. It is built once for
+// every sugared logger of a Logger, so that log calls do not clone the logger.
+func skipCaller(s *zap.SugaredLogger) *zap.SugaredLogger {
+	return s.Desugar().WithOptions(zap.AddCallerSkip(1)).Sugar()
+}
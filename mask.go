@@ -0,0 +1,51 @@
+package flash
+
+import (
+	"regexp"
+
+	"go.uber.org/zap/zapcore"
+)
+
+const mask = "***"
+
+// WithMaskPatterns configures the logger to replace all matches of the patterns in messages
+// and string fields with `***`. All patterns are applied to every logged message and string
+// field, so the cost grows with the number of patterns and the length of the logged text.
+func WithMaskPatterns(patterns ...*regexp.Regexp) Option {
+	return func(c *config) {
+		c.maskPatterns = append(c.maskPatterns, patterns...)
+	}
+}
+
+// masker is a transformer which masks the message and string fields with the patterns.
+type masker []*regexp.Regexp
+
+func (t masker) with(fields []zapcore.Field) ([]zapcore.Field, transformer) {
+	return t.maskFields(make([]zapcore.Field, 0, len(fields)), fields), t
+}
+
+func (t masker) transform(e zapcore.Entry, dst, fields []zapcore.Field) (zapcore.Entry, []zapcore.Field) {
+	e.Message = t.mask(e.Message)
+
+	return e, t.maskFields(dst, fields)
+}
+
+func (t masker) maskFields(dst, fields []zapcore.Field) []zapcore.Field {
+	for _, f := range fields {
+		if f.Type == zapcore.StringType {
+			f.String = t.mask(f.String)
+		}
+
+		dst = append(dst, f)
+	}
+
+	return dst
+}
+
+func (t masker) mask(s string) string {
+	for _, p := range t {
+		s = p.ReplaceAllString(s, mask)
+	}
+
+	return s
+}
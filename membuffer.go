@@ -0,0 +1,80 @@
+package flash
+
+import (
+	"bytes"
+	"os"
+	"sync"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// WithMemoryBuffer buffers the output in memory up to maxBytes, so that bursts of entries
+// do not block on slow sinks. Entries exceeding the buffer are written to stderr instead.
+// The buffer is written to the sinks by Flush, Sync and Close.
+//
+// Entries written to stderr because of a full buffer are not ordered with the buffered
+// entries: they appear on stderr immediately, while older entries are only written to the
+// sinks with the next Flush. Call Flush regularly or use a larger buffer, if the order of
+// the entries matters.
+func WithMemoryBuffer(maxBytes int) Option {
+	return func(c *config) {
+		c.memoryBuffer = &memoryBuffer{
+			max:      maxBytes,
+			overflow: zapcore.Lock(os.Stderr),
+		}
+	}
+}
+
+// Flush writes the entries buffered by WithMemoryBuffer to the sinks. It does nothing
+// without a memory buffer.
+func (l *Logger) Flush() error {
+	if l.memoryBuffer == nil {
+		return nil
+	}
+
+	return l.memoryBuffer.flush()
+}
+
+// memoryBuffer is a zapcore.WriteSyncer which buffers writes to sink up to max bytes.
+// Writes exceeding max are written to overflow.
+type memoryBuffer struct {
+	m        sync.Mutex
+	max      int
+	buf      bytes.Buffer
+	sink     zapcore.WriteSyncer
+	overflow zapcore.WriteSyncer
+}
+
+func (b *memoryBuffer) Write(p []byte) (int, error) {
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	if b.buf.Len()+len(p) > b.max {
+		return b.overflow.Write(p)
+	}
+
+	return b.buf.Write(p)
+}
+
+// Sync writes the buffer to the sink and syncs it.
+func (b *memoryBuffer) Sync() error {
+	if err := b.flush(); err != nil {
+		return err
+	}
+
+	return b.sink.Sync()
+}
+
+func (b *memoryBuffer) flush() error {
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	if b.buf.Len() == 0 {
+		return nil
+	}
+
+	_, err := b.sink.Write(b.buf.Bytes())
+	b.buf.Reset()
+
+	return err
+}
@@ -0,0 +1,38 @@
+package flash
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tj/assert"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestMemoryBuffer(t *testing.T) {
+	sink := &bytes.Buffer{}
+	overflow := &bytes.Buffer{}
+
+	b := &memoryBuffer{
+		max:      10,
+		sink:     zapcore.AddSync(sink),
+		overflow: zapcore.AddSync(overflow),
+	}
+
+	for _, s := range []string{"aaaa\n", "bbbb\n", "cccc\n"} {
+		_, err := b.Write([]byte(s))
+		require.NoError(t, err)
+	}
+
+	assert.Empty(t, sink.String())
+	assert.Equal(t, "cccc\n", overflow.String())
+
+	require.NoError(t, b.flush())
+	assert.Equal(t, "aaaa\nbbbb\n", sink.String())
+
+	_, err := b.Write([]byte("dddd\n"))
+	require.NoError(t, err)
+	require.NoError(t, b.Sync())
+	assert.Equal(t, "aaaa\nbbbb\ndddd\n", sink.String())
+	assert.Equal(t, "cccc\n", overflow.String())
+}
@@ -0,0 +1,58 @@
+package flash
+
+import (
+	"go.uber.org/multierr"
+	"go.uber.org/zap/zapcore"
+)
+
+// Loggers is a group of loggers, for example of several subsystems, which are managed
+// together.
+type Loggers []*Logger
+
+// MultiLogger groups loggers, so that they can be synced, closed and leveled together.
+func MultiLogger(loggers ...*Logger) Loggers {
+	return Loggers(loggers)
+}
+
+// Sync syncs all loggers. It returns the errors of all loggers combined.
+func (ls Loggers) Sync() error {
+	var err error
+
+	for _, l := range ls {
+		err = multierr.Append(err, l.Sync())
+	}
+
+	return err
+}
+
+// Close closes all loggers. It returns the errors of all loggers combined.
+func (ls Loggers) Close() error {
+	var err error
+
+	for _, l := range ls {
+		err = multierr.Append(err, l.Close())
+	}
+
+	return err
+}
+
+// SetLevel sets the level of all loggers.
+func (ls Loggers) SetLevel(level zapcore.Level) {
+	for _, l := range ls {
+		l.SetLevel(level)
+	}
+}
+
+// Disable disables (nearly) all output of all loggers.
+func (ls Loggers) Disable() {
+	for _, l := range ls {
+		l.Disable()
+	}
+}
+
+// Enable restores the levels the loggers had before Disable was called.
+func (ls Loggers) Enable() {
+	for _, l := range ls {
+		l.Enable()
+	}
+}
@@ -0,0 +1,36 @@
+package flash_test
+
+import (
+	"testing"
+
+	"github.com/postfinance/flash"
+	"github.com/stretchr/testify/require"
+	"github.com/tj/assert"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestMultiLogger(t *testing.T) {
+	defer sink.Reset()
+
+	l1 := flash.New(flash.WithSinks("memory://"), flash.WithMemoryBuffer(1<<20))
+	l2 := flash.New(flash.WithSinks("memory://"), flash.WithMemoryBuffer(1<<20))
+
+	loggers := flash.MultiLogger(l1, l2)
+	loggers.SetLevel(zapcore.DebugLevel)
+
+	l1.Debug("first")
+	l2.Debug("second")
+
+	loggers.Disable()
+	l1.Error("disabled")
+	loggers.Enable()
+
+	assert.Empty(t, sink.String())
+	require.NoError(t, loggers.Close())
+
+	e, err := sink.parse()
+	require.NoError(t, err)
+	require.Len(t, e, 2)
+	assert.Equal(t, "first", e[0].Msg)
+	assert.Equal(t, "second", e[1].Msg)
+}
@@ -0,0 +1,71 @@
+package flash
+
+import (
+	"fmt"
+	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Printer is implemented by loggers with a minimal Printf/Println interface as
+// accepted by many libraries.
+type Printer interface {
+	Printf(format string, args ...interface{})
+	Println(args ...interface{})
+}
+
+// StdPrinter returns a Printer which logs all messages at the given level.
+func (l *Logger) StdPrinter(level zapcore.Level) Printer {
+	p := &printer{
+		l:     l,
+		level: level,
+	}
+	p.logger()
+
+	return p
+}
+
+type printer struct {
+	l     *Logger
+	level zapcore.Level
+
+	m      sync.Mutex
+	base   *zap.SugaredLogger
+	cached *zap.Logger
+}
+
+// Printf logs a message formatted with fmt.Sprintf.
+func (p *printer) Printf(format string, args ...interface{}) {
+	p.log(fmt.Sprintf(format, args...))
+}
+
+// Println logs a message formatted with fmt.Sprintln. The trailing newline is removed.
+func (p *printer) Println(args ...interface{}) {
+	msg := fmt.Sprintln(args...)
+	p.log(msg[:len(msg)-1])
+}
+
+func (p *printer) log(msg string) {
+	if ce := p.logger().Check(p.level, msg); ce != nil {
+		ce.Write()
+	}
+}
+
+// logger returns the logger skipping the frames of the printer. It is only rebuilt, if
+// the stacktrace level of the logger was changed, for example by SetDebug.
+func (p *printer) logger() *zap.Logger {
+	p.l.m.Lock()
+	base := p.l.SugaredLogger
+	p.l.m.Unlock()
+
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	if base != p.base {
+		p.base = base
+		p.cached = base.Desugar().WithOptions(zap.AddCallerSkip(2))
+	}
+
+	return p.cached
+}
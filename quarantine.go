@@ -0,0 +1,121 @@
+package flash
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// WithSinkQuarantine stops writing to a sink after threshold consecutive failed writes, for
+// example if stderr is closed, instead of reporting every failed write to the error output.
+// A single error is reported, when a sink is quarantined. After retry, the next write is
+// tried again and re-enables the sink, if it succeeds. If retry is zero, the sink stays
+// quarantined.
+func WithSinkQuarantine(threshold int, retry time.Duration) Option {
+	return func(c *config) {
+		c.quarantine = &quarantineConfig{
+			threshold: threshold,
+			retry:     retry,
+		}
+	}
+}
+
+type quarantineConfig struct {
+	threshold int
+	retry     time.Duration
+}
+
+// openQuarantinedSinks opens the sinks separately, so that each one is quarantined on
+// its own. It returns a function to close all sinks.
+func openQuarantinedSinks(q quarantineConfig, paths []string, errOut zapcore.WriteSyncer) (zapcore.WriteSyncer, func(), error) {
+	sinks := make([]zapcore.WriteSyncer, 0, len(paths))
+	closers := make([]func(), 0, len(paths))
+
+	closeAll := func() {
+		for _, c := range closers {
+			c()
+		}
+	}
+
+	for _, path := range paths {
+		sink, closeSink, err := zap.Open(path)
+		if err != nil {
+			closeAll()
+			return nil, nil, err
+		}
+
+		sinks = append(sinks, &quarantineSink{
+			WriteSyncer: sink,
+			path:        path,
+			config:      q,
+			errOut:      errOut,
+		})
+		closers = append(closers, closeSink)
+	}
+
+	return zapcore.NewMultiWriteSyncer(sinks...), closeAll, nil
+}
+
+// quarantineSink is a zapcore.WriteSyncer, which drops all writes after threshold
+// consecutive failed writes until the retry interval has passed.
+type quarantineSink struct {
+	zapcore.WriteSyncer
+	path   string
+	config quarantineConfig
+	errOut zapcore.WriteSyncer
+
+	m           sync.Mutex
+	failures    int
+	quarantined bool
+	retryAt     time.Time
+}
+
+func (s *quarantineSink) Write(p []byte) (int, error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if s.quarantined && (s.config.retry <= 0 || time.Now().Before(s.retryAt)) {
+		return len(p), nil
+	}
+
+	n, err := s.WriteSyncer.Write(p)
+	if err == nil {
+		s.failures = 0
+		s.quarantined = false
+
+		return n, nil
+	}
+
+	if s.quarantined {
+		s.retryAt = time.Now().Add(s.config.retry)
+		return len(p), nil
+	}
+
+	s.failures++
+	if s.failures < s.config.threshold {
+		return n, err
+	}
+
+	s.quarantined = true
+	s.retryAt = time.Now().Add(s.config.retry)
+
+	fmt.Fprintf(s.errOut, "%v flash: quarantined sink %s after %d failed writes: %v\n", time.Now(), s.path, s.failures, err)
+	_ = s.errOut.Sync()
+
+	return len(p), nil
+}
+
+func (s *quarantineSink) Sync() error {
+	s.m.Lock()
+	quarantined := s.quarantined
+	s.m.Unlock()
+
+	if quarantined {
+		return nil
+	}
+
+	return s.WriteSyncer.Sync()
+}
@@ -0,0 +1,53 @@
+package flash
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tj/assert"
+	"go.uber.org/zap/zapcore"
+)
+
+// failingWriter counts the writes, which all fail.
+type failingWriter struct {
+	writes int
+}
+
+func (w *failingWriter) Write([]byte) (int, error) {
+	w.writes++
+	return 0, errors.New("broken pipe")
+}
+
+func TestQuarantineSink(t *testing.T) {
+	w := &failingWriter{}
+	errOut := &bytes.Buffer{}
+
+	s := &quarantineSink{
+		WriteSyncer: zapcore.AddSync(w),
+		path:        "stderr",
+		config:      quarantineConfig{threshold: 3, retry: 50 * time.Millisecond},
+		errOut:      zapcore.AddSync(errOut),
+	}
+
+	failed := 0
+
+	for i := 0; i < 10; i++ {
+		if _, err := s.Write([]byte("entry\n")); err != nil {
+			failed++
+		}
+	}
+
+	assert.Equal(t, 3, w.writes)
+	assert.Equal(t, 2, failed)
+	assert.Equal(t, 1, strings.Count(errOut.String(), "quarantined sink stderr"))
+
+	time.Sleep(60 * time.Millisecond)
+
+	_, err := s.Write([]byte("entry\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, 4, w.writes)
+	assert.Equal(t, 1, strings.Count(errOut.String(), "quarantined sink"))
+}
@@ -0,0 +1,75 @@
+package flash
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"go.uber.org/zap"
+)
+
+// WithReopenOnSIGHUP reopens the log files on SIGHUP, see Logger.ReopenFiles. The signal
+// handler is stopped by Close.
+func WithReopenOnSIGHUP() Option {
+	return func(c *config) {
+		c.reopenOnSIGHUP = true
+	}
+}
+
+// ReopenFiles closes the log files configured with WithFile or WithConsoleAndFile. The
+// files are reopened by the next write, so that a file renamed by an external tool like
+// logrotate is created again at its configured path.
+func (l *Logger) ReopenFiles() error {
+	for _, path := range l.files {
+		if err := fileSinks.reopen(path); err != nil {
+			return fmt.Errorf("could not reopen %s: %w", path, err)
+		}
+	}
+
+	return nil
+}
+
+// reopen closes the file of the registered sink for path.
+func (r *fileSinkRegistry) reopen(path string) error {
+	r.m.Lock()
+	s, ok := r.sinks[fileSinkKey(path)]
+	r.m.Unlock()
+
+	if !ok {
+		return nil
+	}
+
+	if s.utf16 != nil {
+		return s.utf16.reopen(s.Logger.Close)
+	}
+
+	return s.Logger.Close()
+}
+
+// reopenOnSIGHUP calls ReopenFiles on every SIGHUP. It returns a function to stop the
+// signal handler.
+func (l *Logger) reopenOnSIGHUP() func() {
+	signals := make(chan os.Signal, 1)
+	done := make(chan struct{})
+
+	signal.Notify(signals, syscall.SIGHUP)
+
+	go func() {
+		for {
+			select {
+			case <-signals:
+				if err := l.ReopenFiles(); err != nil {
+					l.base.Error("could not reopen log files", zap.Error(err))
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
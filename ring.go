@@ -0,0 +1,62 @@
+package flash
+
+import (
+	"strings"
+	"sync"
+)
+
+// ringBuffer is a zapcore.WriteSyncer which keeps the last written entries.
+type ringBuffer struct {
+	m       sync.Mutex
+	entries []string
+	next    int
+	full    bool
+}
+
+func newRingBuffer(n int) *ringBuffer {
+	return &ringBuffer{
+		entries: make([]string, n),
+	}
+}
+
+// Write stores p as entry. zapcore.Core writes each entry with a single call of Write.
+func (r *ringBuffer) Write(p []byte) (int, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	r.entries[r.next] = strings.TrimSuffix(string(p), "\n")
+	r.next = (r.next + 1) % len(r.entries)
+
+	if r.next == 0 {
+		r.full = true
+	}
+
+	return len(p), nil
+}
+
+// Sync implements zapcore.WriteSyncer.
+func (r *ringBuffer) Sync() error {
+	return nil
+}
+
+// tail returns the stored entries ordered from the oldest to the newest.
+func (r *ringBuffer) tail() []string {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	if !r.full {
+		return append([]string(nil), r.entries[:r.next]...)
+	}
+
+	return append(append(make([]string, 0, len(r.entries)), r.entries[r.next:]...), r.entries[:r.next]...)
+}
+
+// Tail returns the last log entries encoded with the configured encoder, if the logger
+// is configured WithRingBuffer. The entries are ordered from the oldest to the newest.
+func (l *Logger) Tail() []string {
+	if l.ring == nil {
+		return nil
+	}
+
+	return l.ring.tail()
+}
@@ -0,0 +1,147 @@
+package flash
+
+import (
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	sampleTick     = time.Second
+	sampleCounters = 4096
+	noSampleKey    = "flash.nosample"
+)
+
+// noSample marks a logger to bypass the sampling. It is not encoded.
+// nolint: gochecknoglobals
+var noSample = zapcore.Field{Key: noSampleKey, Type: zapcore.SkipType}
+
+// NoSample returns a logger, which logs all entries, even if sampling is configured.
+func (l *Logger) NoSample() *zap.SugaredLogger {
+	return l.Desugar().With(noSample).Sugar()
+}
+
+// SampleRate configures the sampling of a level. Per second, the first Initial entries
+// with the same level and message are logged. Thereafter only every Thereafter-th entry
+// is logged. If Thereafter is zero, all entries after the first Initial are dropped.
+type SampleRate struct {
+	Initial    int
+	Thereafter int
+}
+
+// samplingCore is a zapcore.Core wrapper which samples entries per level.
+type samplingCore struct {
+	zapcore.Core
+	rates  map[zapcore.Level]SampleRate
+	counts map[zapcore.Level]*sampleCounts
+	hook   func(zapcore.Entry, bool)
+	skip   bool
+}
+
+type sampleCounts [sampleCounters]sampleCounter
+
+func newSamplingCore(core zapcore.Core, rates map[zapcore.Level]SampleRate, hook func(zapcore.Entry, bool)) zapcore.Core {
+	counts := make(map[zapcore.Level]*sampleCounts, len(rates))
+	for lvl := range rates {
+		counts[lvl] = &sampleCounts{}
+	}
+
+	return &samplingCore{
+		Core:   core,
+		rates:  rates,
+		counts: counts,
+		hook:   hook,
+	}
+}
+
+func (c *samplingCore) With(fields []zapcore.Field) zapcore.Core {
+	skip := c.skip
+
+	for i := range fields {
+		if fields[i].Type == zapcore.SkipType && fields[i].Key == noSampleKey {
+			skip = true
+		}
+	}
+
+	return &samplingCore{
+		Core:   c.Core.With(fields),
+		rates:  c.rates,
+		counts: c.counts,
+		hook:   c.hook,
+		skip:   skip,
+	}
+}
+
+func (c *samplingCore) Check(e zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.Enabled(e.Level) {
+		return ce
+	}
+
+	if !c.skip && !c.sample(e) {
+		return ce
+	}
+
+	return c.Core.Check(e, ce)
+}
+
+// sample returns true, if the entry should be logged.
+func (c *samplingCore) sample(e zapcore.Entry) bool {
+	rate, ok := c.rates[e.Level]
+	if !ok {
+		return true
+	}
+
+	counter := &c.counts[e.Level][fnv32a(e.Message)%sampleCounters]
+	n := counter.inc(e.Time)
+
+	sampled := n <= uint64(rate.Initial) ||
+		rate.Thereafter > 0 && (n-uint64(rate.Initial))%uint64(rate.Thereafter) == 0
+
+	if c.hook != nil {
+		c.hook(e, sampled)
+	}
+
+	return sampled
+}
+
+type sampleCounter struct {
+	resetAt int64
+	n       uint64
+}
+
+// inc increments the counter and resets it, if the current tick has passed.
+func (c *sampleCounter) inc(t time.Time) uint64 {
+	tn := t.UnixNano()
+
+	resetAt := atomic.LoadInt64(&c.resetAt)
+	if resetAt > tn {
+		return atomic.AddUint64(&c.n, 1)
+	}
+
+	atomic.StoreUint64(&c.n, 1)
+
+	if !atomic.CompareAndSwapInt64(&c.resetAt, resetAt, tn+sampleTick.Nanoseconds()) {
+		// another goroutine reset the counter
+		return atomic.AddUint64(&c.n, 1)
+	}
+
+	return 1
+}
+
+// fnv32a is the 32-bit FNV-1a hash of s.
+func fnv32a(s string) uint32 {
+	const (
+		offset32 = 2166136261
+		prime32  = 16777619
+	)
+
+	hash := uint32(offset32)
+	for i := 0; i < len(s); i++ {
+		hash ^= uint32(s[i])
+		hash *= prime32
+	}
+
+	return hash
+}
@@ -0,0 +1,195 @@
+package flash
+
+import (
+	"errors"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// stackFrame is a single frame of a stacktrace.
+type stackFrame struct {
+	Function string
+	File     string
+	Line     int
+}
+
+// MarshalLogObject implements zapcore.ObjectMarshaler.
+func (f stackFrame) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("function", f.Function)
+	enc.AddString("file", f.File)
+	enc.AddInt("line", f.Line)
+
+	return nil
+}
+
+type stackFrames []stackFrame
+
+// MarshalLogArray implements zapcore.ArrayMarshaler.
+func (frames stackFrames) MarshalLogArray(enc zapcore.ArrayEncoder) error {
+	for _, f := range frames {
+		if err := enc.AppendObject(f); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// parseStack parses a stacktrace in the format created by zap, where each frame
+// consists of a function line followed by an indented file:line line.
+func parseStack(stack string) stackFrames {
+	lines := strings.Split(strings.TrimSpace(stack), "\n")
+	frames := make(stackFrames, 0, len(lines)/2)
+
+	for i := 0; i+1 < len(lines); i += 2 {
+		f := stackFrame{
+			Function: lines[i],
+			File:     strings.TrimSpace(lines[i+1]),
+		}
+
+		if idx := strings.LastIndex(f.File, ":"); idx > 0 {
+			if line, err := strconv.Atoi(f.File[idx+1:]); err == nil {
+				f.File = f.File[:idx]
+				f.Line = line
+			}
+		}
+
+		frames = append(frames, f)
+	}
+
+	return frames
+}
+
+// structuredStack is a transformer which replaces the stacktrace of an entry with an array
+// of frames in a field with the key.
+type structuredStack string
+
+func (t structuredStack) transform(e zapcore.Entry, dst, fields []zapcore.Field) (zapcore.Entry, []zapcore.Field) {
+	dst = append(dst, fields...)
+
+	if e.Stack == "" {
+		return e, dst
+	}
+
+	dst = append(dst, zap.Array(string(t), parseStack(e.Stack)))
+	e.Stack = ""
+
+	return e, dst
+}
+
+// WithStacktraceExcludes omits the stacktrace of all entries, for which exclude returns
+// true, for example for expected errors like context.Canceled. See ExcludeErrors. The
+// fields passed to exclude include the context fields added with With.
+//
+// Only fields are seen by exclude: an error formatted into the message of a sugared
+// logger, for example with Errorf, is not matched by ExcludeErrors. Pass the error as
+// field with Errorw instead.
+func WithStacktraceExcludes(exclude func(zapcore.Entry, []zapcore.Field) bool) Option {
+	return func(c *config) {
+		c.stacktraceExcludes = append(c.stacktraceExcludes, exclude)
+	}
+}
+
+// ExcludeErrors returns a function for WithStacktraceExcludes, which matches entries with
+// an error field matching one of targets with errors.Is.
+func ExcludeErrors(targets ...error) func(zapcore.Entry, []zapcore.Field) bool {
+	return func(_ zapcore.Entry, fields []zapcore.Field) bool {
+		for i := range fields {
+			if fields[i].Type != zapcore.ErrorType {
+				continue
+			}
+
+			err, ok := fields[i].Interface.(error)
+			if !ok {
+				continue
+			}
+
+			for _, target := range targets {
+				if errors.Is(err, target) {
+					return true
+				}
+			}
+		}
+
+		return false
+	}
+}
+
+// stackExclude is a transformer which removes the stacktrace from entries matching one of
+// the excludes. The excludes get the context fields added with With before the fields of
+// the entry.
+type stackExclude struct {
+	excludes []func(zapcore.Entry, []zapcore.Field) bool
+	context  []zapcore.Field
+}
+
+func (t *stackExclude) with(fields []zapcore.Field) ([]zapcore.Field, transformer) {
+	context := make([]zapcore.Field, 0, len(t.context)+len(fields))
+	context = append(context, t.context...)
+
+	return fields, &stackExclude{
+		excludes: t.excludes,
+		context:  append(context, fields...),
+	}
+}
+
+func (t *stackExclude) transform(e zapcore.Entry, dst, fields []zapcore.Field) (zapcore.Entry, []zapcore.Field) {
+	if e.Stack == "" {
+		return e, append(dst, fields...)
+	}
+
+	all := fields
+	if len(t.context) > 0 {
+		all = make([]zapcore.Field, 0, len(t.context)+len(fields))
+		all = append(all, t.context...)
+		all = append(all, fields...)
+	}
+
+	for _, exclude := range t.excludes {
+		if exclude(e, all) {
+			e.Stack = ""
+			break
+		}
+	}
+
+	return e, append(dst, fields...)
+}
+
+// WithCallStackDepth annotates logs with the number of frames on the call stack of the
+// logging goroutine in a field with key, for example to debug deep recursions. The frames
+// of flash and zap are included, so only differences between entries are meaningful.
+// Counting the frames walks the whole stack for every entry, so the option is meant for
+// debugging only.
+func WithCallStackDepth(key string) Option {
+	return func(c *config) {
+		c.stackDepthKey = key
+	}
+}
+
+// stackDepth returns the number of frames on the call stack of the current goroutine.
+func stackDepth() int {
+	pcs := make([]uintptr, 64)
+
+	for {
+		n := runtime.Callers(0, pcs)
+		if n < len(pcs) {
+			return n
+		}
+
+		pcs = make([]uintptr, 2*len(pcs))
+	}
+}
+
+// stackDepthField is a transformer which adds the depth of the call stack to every entry
+// in a field with the key.
+type stackDepthField string
+
+func (t stackDepthField) transform(e zapcore.Entry, dst, fields []zapcore.Field) (zapcore.Entry, []zapcore.Field) {
+	dst = append(dst, zap.Int(string(t), stackDepth()))
+
+	return e, append(dst, fields...)
+}
@@ -0,0 +1,108 @@
+package flash
+
+import (
+	"os"
+	"time"
+
+	"github.com/mattn/go-isatty"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// WithConsoleAndFile configures the logger to log with the console encoder to stderr and
+// additionally with the JSON encoder into a file. The console output is colored, if stderr
+// is a terminal. The console sink can be changed with WithSinks.
+func WithConsoleAndFile(fc FileConfig) Option {
+	return func(c *config) {
+		c.encoder = Console
+		c.enableColor = isatty.IsTerminal(os.Stderr.Fd())
+		c.sinks = []string{"stderr"}
+		c.teeFile = &fc
+	}
+}
+
+// WithFileTimeUTC encodes the timestamps of entries written into files in UTC, while other
+// outputs like the console of WithConsoleAndFile keep the local time.
+func WithFileTimeUTC() Option {
+	return func(c *config) {
+		c.fileTimeUTC = true
+	}
+}
+
+// utcTimeEncoder returns a time encoder, which converts the time to UTC before encoding
+// it with enc.
+func utcTimeEncoder(enc zapcore.TimeEncoder) zapcore.TimeEncoder {
+	if enc == nil {
+		return nil
+	}
+
+	return func(t time.Time, pae zapcore.PrimitiveArrayEncoder) {
+		enc(t.UTC(), pae)
+	}
+}
+
+// WithDebugFile configures the logger to additionally log with the JSON encoder into a
+// file while debug mode is enabled, for example with SetDebug(true). Debug entries are only
+// written into the debug file, all other outputs keep logging at info level or above.
+func WithDebugFile(fc FileConfig) Option {
+	return func(c *config) {
+		c.debugFile = &fc
+	}
+}
+
+// debugMode is a zapcore.LevelEnabler enabling all levels while atom is at debug level.
+type debugMode struct {
+	atom zap.AtomicLevel
+}
+
+func (d debugMode) Enabled(zapcore.Level) bool {
+	return d.atom.Enabled(zapcore.DebugLevel)
+}
+
+// minLevel is a zapcore.LevelEnabler enabling the levels of the embedded level enabler at
+// or above min.
+type minLevel struct {
+	zapcore.LevelEnabler
+	min zapcore.Level
+}
+
+func (m minLevel) Enabled(level zapcore.Level) bool {
+	return level >= m.min && m.LevelEnabler.Enabled(level)
+}
+
+// enabledCore is a zapcore.Core wrapper which only writes entries enabled by the wrapped
+// core. Core wrappers add themselves to checked entries and write to all cores of a tee,
+// regardless of their levels.
+type enabledCore struct {
+	zapcore.Core
+}
+
+func (c *enabledCore) With(fields []zapcore.Field) zapcore.Core {
+	return &enabledCore{Core: c.Core.With(fields)}
+}
+
+func (c *enabledCore) Write(e zapcore.Entry, fields []zapcore.Field) error {
+	if !c.Enabled(e.Level) {
+		return nil
+	}
+
+	return c.Core.Write(e, fields)
+}
+
+// newFileCore creates a core logging with the JSON encoder into the file. It returns
+// a function to close the file.
+func newFileCore(fc FileConfig, encoderConfig zapcore.EncoderConfig, level zapcore.LevelEnabler) (zapcore.Core, func(), error) {
+	if err := fileSinks.register(fc); err != nil {
+		return nil, nil, err
+	}
+
+	sink, closeSink, err := zap.Open(fc.sinkURI())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// no colors when logging to file
+	encoderConfig.EncodeLevel = zapcore.CapitalLevelEncoder
+
+	return zapcore.NewCore(zapcore.NewJSONEncoder(encoderConfig), sink, level), closeSink, nil
+}
@@ -0,0 +1,13 @@
+// Package logging contains logging helpers to test the wrapper detection of flash.
+package logging
+
+import "github.com/postfinance/flash"
+
+// Info logs msg at info level with l.
+func Info(l *flash.Logger, msg string) {
+	info(l, msg)
+}
+
+func info(l *flash.Logger, msg string) {
+	l.Info(msg)
+}
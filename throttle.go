@@ -0,0 +1,95 @@
+package flash
+
+import (
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// WithThrottle logs at most max entries with the same message within a sliding window. All
+// further entries with the message are dropped, until the oldest logged entry leaves the
+// window. Entries at `DPanicLevel` and above are never dropped.
+func WithThrottle(window time.Duration, max int) Option {
+	return func(c *config) {
+		c.throttle = &throttle{
+			window: window,
+			max:    max,
+			seen:   map[string][]time.Time{},
+		}
+	}
+}
+
+// throttle tracks the times of the logged entries per message.
+type throttle struct {
+	m         sync.Mutex
+	window    time.Duration
+	max       int
+	seen      map[string][]time.Time
+	lastSweep time.Time
+}
+
+// allow returns true, if the entry should be logged.
+func (t *throttle) allow(e zapcore.Entry) bool {
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	start := e.Time.Add(-t.window)
+
+	if e.Time.Sub(t.lastSweep) > t.window {
+		t.sweep(start)
+		t.lastSweep = e.Time
+	}
+
+	times := t.seen[e.Message]
+
+	i := 0
+	for i < len(times) && !times[i].After(start) {
+		i++
+	}
+
+	times = times[i:]
+
+	if len(times) >= t.max {
+		t.seen[e.Message] = times
+		return false
+	}
+
+	t.seen[e.Message] = append(times, e.Time)
+
+	return true
+}
+
+// sweep removes all messages without entries after start.
+func (t *throttle) sweep(start time.Time) {
+	for msg, times := range t.seen {
+		if len(times) == 0 || !times[len(times)-1].After(start) {
+			delete(t.seen, msg)
+		}
+	}
+}
+
+// throttleCore is a zapcore.Core wrapper which drops throttled entries.
+type throttleCore struct {
+	zapcore.Core
+	throttle *throttle
+}
+
+func (c *throttleCore) With(fields []zapcore.Field) zapcore.Core {
+	return &throttleCore{
+		Core:     c.Core.With(fields),
+		throttle: c.throttle,
+	}
+}
+
+func (c *throttleCore) Check(e zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.Enabled(e.Level) {
+		return ce
+	}
+
+	if e.Level < zapcore.DPanicLevel && !c.throttle.allow(e) {
+		return ce
+	}
+
+	return c.Core.Check(e, ce)
+}
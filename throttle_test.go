@@ -0,0 +1,25 @@
+package flash
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tj/assert"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestThrottleWindow(t *testing.T) {
+	th := &throttle{window: time.Second, max: 2, seen: map[string][]time.Time{}}
+	start := time.Now()
+
+	allow := func(d time.Duration) bool {
+		return th.allow(zapcore.Entry{Message: "message", Time: start.Add(d)})
+	}
+
+	assert.True(t, allow(0))
+	assert.True(t, allow(100*time.Millisecond))
+	assert.False(t, allow(500*time.Millisecond))
+	assert.True(t, allow(1001*time.Millisecond))
+	assert.False(t, allow(1050*time.Millisecond))
+	assert.True(t, allow(1101*time.Millisecond))
+}
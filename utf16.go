@@ -0,0 +1,124 @@
+package flash
+
+import (
+	"encoding/binary"
+	"os"
+	"sync"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+const (
+	megabyte           = 1024 * 1024
+	defaultMaxFileSize = 100 // lumberjack default in megabytes
+)
+
+// nolint: gochecknoglobals
+var utf16BOM = []byte{0xff, 0xfe}
+
+// WithUTF16BOM writes log files in UTF-16LE with a byte order mark at the beginning of each
+// file, for example for legacy Windows log ingesters. It applies only to files configured
+// with WithFile, WithConsoleAndFile or WithDebugFile. The sizes of rotated files are counted
+// in UTF-16 bytes.
+func WithUTF16BOM() Option {
+	return func(c *config) {
+		c.utf16 = true
+	}
+}
+
+// utf16Encoder transcodes writes to a file to UTF-16LE and prepends the byte order mark
+// to the first write of each new file. The size of the file is read once with the first
+// write and then tracked, to detect rotations without a stat call per entry. Files rotated
+// or truncated by other processes are therefore not detected.
+type utf16Encoder struct {
+	m     sync.Mutex
+	known bool
+	size  int64
+}
+
+// write encodes p and writes it to l. It returns len(p) on success.
+func (e *utf16Encoder) write(l *lumberjack.Logger, p []byte) (int, error) {
+	buf := encodeUTF16(p)
+
+	e.m.Lock()
+	defer e.m.Unlock()
+
+	if e.newFile(l, len(buf)) {
+		buf = append(append([]byte(nil), utf16BOM...), buf...)
+		e.size = 0
+	}
+
+	n, err := l.Write(buf)
+	e.size += int64(n)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// newFile reports whether a write of n bytes starts a new file, because the file does not
+// exist yet, is empty or is rotated before the write.
+func (e *utf16Encoder) newFile(l *lumberjack.Logger, n int) bool {
+	if !e.known {
+		e.known = true
+
+		if info, err := os.Stat(l.Filename); err == nil {
+			e.size = info.Size()
+		}
+	}
+
+	if e.size == 0 {
+		return true
+	}
+
+	maxSize := l.MaxSize
+	if maxSize == 0 {
+		maxSize = defaultMaxFileSize
+	}
+
+	return e.size+int64(n) > int64(maxSize)*megabyte
+}
+
+// reopen closes the file with close and forgets its size, so that the next write reads
+// the size of the reopened file again and starts a new file with a byte order mark.
+func (e *utf16Encoder) reopen(close func() error) error {
+	e.m.Lock()
+	defer e.m.Unlock()
+
+	e.known = false
+	e.size = 0
+
+	return close()
+}
+
+// encodeUTF16 transcodes the UTF-8 bytes p to UTF-16LE. Invalid UTF-8 is replaced with
+// the Unicode replacement character.
+func encodeUTF16(p []byte) []byte {
+	buf := make([]byte, 0, 2*len(p))
+
+	for len(p) > 0 {
+		r, size := utf8.DecodeRune(p)
+		p = p[size:]
+
+		if r1, r2 := utf16.EncodeRune(r); r1 != utf8.RuneError {
+			buf = appendUint16(appendUint16(buf, uint16(r1)), uint16(r2))
+			continue
+		}
+
+		buf = appendUint16(buf, uint16(r))
+	}
+
+	return buf
+}
+
+func appendUint16(buf []byte, v uint16) []byte {
+	var b [2]byte
+
+	binary.LittleEndian.PutUint16(b[:], v)
+
+	return append(buf, b[:]...)
+}
@@ -0,0 +1,211 @@
+package flash
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const sinkValidationTimeout = 2 * time.Second
+
+// nolint: gochecknoglobals
+var networkSchemes = map[string]string{
+	"tcp":  "tcp",
+	"tcp4": "tcp4",
+	"tcp6": "tcp6",
+	"unix": "unix",
+}
+
+// ValidateSinks checks each configured sink and returns an error listing all sinks which
+// are not usable. No entries are written to the sinks and no files are created:
+//
+//   - for files, the file or its directory must exist and be writable
+//   - for network sinks like `tcp://host:port` a connection is established with a timeout
+//     of 2 seconds
+//   - other registered sinks are opened and closed, with the same timeout
+//
+// The files of WithConsoleAndFile and WithDebugFile are validated like file sinks and the
+// socket of WithJournald is connected like a network sink. If the logger uses fallback
+// sinks, the originally configured sinks are validated.
+func (l *Logger) ValidateSinks() error {
+	var failed []string
+
+	for _, path := range l.sinks {
+		if err := validateSink(path, sinkValidationTimeout); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %s", path, err))
+		}
+	}
+
+	for _, path := range l.teeFiles {
+		if err := validateFile(path); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %s", path, err))
+		}
+	}
+
+	if l.journaldSocket != "" {
+		if err := validateJournald(l.journaldSocket, sinkValidationTimeout); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %s", l.journaldSocket, err))
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("invalid sinks: %s", strings.Join(failed, "; "))
+	}
+
+	return nil
+}
+
+// validateSink checks the sink with the given path like zap.Open would open it.
+func validateSink(path string, timeout time.Duration) error {
+	if path == "stderr" || path == "stdout" || filepath.IsAbs(path) {
+		return validateFile(path)
+	}
+
+	u, err := url.Parse(path)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case u.Scheme == "" || u.Scheme == "file":
+		return validateFile(u.Path)
+	case u.Scheme == lumberjackSinkURIPrefix:
+		return validateFile(pathFromURI(u))
+	case networkSchemes[u.Scheme] != "":
+		addr := u.Host
+		if u.Scheme == "unix" {
+			addr = u.Path
+		}
+
+		conn, err := net.DialTimeout(networkSchemes[u.Scheme], addr, timeout)
+		if err != nil {
+			return err
+		}
+
+		return conn.Close()
+	default:
+		return openWithTimeout(path, timeout)
+	}
+}
+
+// validateJournald connects to the journal socket without sending an entry.
+func validateJournald(socket string, timeout time.Duration) error {
+	conn, err := net.DialTimeout("unixgram", socket, timeout)
+	if err != nil {
+		return err
+	}
+
+	return conn.Close()
+}
+
+// validateFile checks that the file at path is writable, or that its directory exists, if
+// the file does not exist yet.
+func validateFile(path string) error {
+	if path == "stderr" || path == "stdout" {
+		return nil
+	}
+
+	info, err := os.Stat(path)
+
+	switch {
+	case os.IsNotExist(err):
+		dir, err := os.Stat(filepath.Dir(path))
+		if err != nil {
+			return err
+		}
+
+		if !dir.IsDir() {
+			return fmt.Errorf("%s is not a directory", filepath.Dir(path))
+		}
+
+		return nil
+	case err != nil:
+		return err
+	case info.IsDir():
+		return fmt.Errorf("%s is a directory", path)
+	case info.Mode().Perm()&0o222 == 0:
+		return fmt.Errorf("%s is not writable", path)
+	default:
+		return nil
+	}
+}
+
+// openWithTimeout opens and closes the sink with zap.Open. It returns an error, if opening
+// takes longer than timeout.
+func openWithTimeout(path string, timeout time.Duration) error {
+	errc := make(chan error, 1)
+
+	go func() {
+		_, closeSink, err := zap.Open(path)
+		if err == nil {
+			closeSink()
+		}
+
+		errc <- err
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-time.After(timeout):
+		return fmt.Errorf("could not open sink within %s", timeout)
+	}
+}
+
+// ValidateLogFile checks that each line of the log file at path, written with the JSON
+// encoder, is valid JSON, for example to verify the integrity of rotated files. Files
+// with a `.gz` suffix are decompressed. It returns the number of non-empty lines and an
+// error with the line number of the first invalid line.
+func ValidateLogFile(path string) (lines int, firstError error) {
+	f, err := os.Open(path) // nolint: gosec
+	if err != nil {
+		return 0, err
+	}
+
+	defer f.Close() // nolint: errcheck
+
+	var r io.Reader = f
+
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return 0, fmt.Errorf("could not decompress %s: %w", path, err)
+		}
+
+		defer gz.Close() // nolint: errcheck
+
+		r = gz
+	}
+
+	br := bufio.NewReader(r)
+
+	for number := 1; ; number++ {
+		line, err := br.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return lines, fmt.Errorf("could not read %s: %w", path, err)
+		}
+
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			lines++
+
+			if !json.Valid(line) && firstError == nil {
+				firstError = fmt.Errorf("%s:%d: invalid JSON", path, number)
+			}
+		}
+
+		if err == io.EOF {
+			return lines, firstError
+		}
+	}
+}
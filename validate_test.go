@@ -0,0 +1,125 @@
+package flash_test
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/postfinance/flash"
+	"github.com/stretchr/testify/require"
+	"github.com/tj/assert"
+)
+
+func TestValidateLogFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "flash")
+	require.NoError(t, err)
+
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	content := "{\"level\":\"INFO\",\"msg\":\"first\"}\n{\"level\":\"INFO\",\"msg\":\"sec\n{\"level\":\"INFO\",\"msg\":\"third\"}\n"
+
+	path := filepath.Join(dir, "app.log")
+	require.NoError(t, ioutil.WriteFile(path, []byte(content), 0o600))
+
+	gzPath := filepath.Join(dir, "app-1.log.gz")
+	f, err := os.Create(gzPath)
+	require.NoError(t, err)
+
+	gz := gzip.NewWriter(f)
+	_, err = gz.Write([]byte(content))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+	require.NoError(t, f.Close())
+
+	for _, p := range []string{path, gzPath} {
+		lines, err := flash.ValidateLogFile(p)
+		assert.Equal(t, 3, lines, p)
+		require.Error(t, err, p)
+		assert.Equal(t, p+":2: invalid JSON", err.Error())
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		l := flash.New(flash.WithFile(flash.FileConfig{Path: filepath.Join(dir, "valid.log")}), flash.WithEncoder(flash.JSON))
+		l.Info("first")
+		l.Infow("second", "key", "value")
+		require.NoError(t, l.Close())
+
+		lines, err := flash.ValidateLogFile(filepath.Join(dir, "valid.log"))
+		require.NoError(t, err)
+		assert.Equal(t, 2, lines)
+	})
+}
+
+func TestValidateSinksNetwork(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	reachable := "tcp://" + ln.Addr().String()
+
+	defer ln.Close()
+
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	unreachable := "tcp://" + closed.Addr().String()
+	require.NoError(t, closed.Close())
+
+	l := flash.New(flash.WithSinks(reachable, unreachable), flash.WithFallbackSink("memory://"))
+
+	start := time.Now()
+	err = l.ValidateSinks()
+
+	assert.True(t, time.Since(start) < 2*time.Second)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), unreachable)
+	assert.NotContains(t, err.Error(), reachable)
+}
+
+func TestValidateSinksFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "flash")
+	require.NoError(t, err)
+
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	path := filepath.Join(dir, "app.log")
+
+	l := flash.New(flash.WithFile(flash.FileConfig{Path: path}))
+	l.Info("before")
+	require.NoError(t, l.ValidateSinks())
+	l.Info("after")
+	require.NoError(t, l.Sync())
+
+	lines, err := flash.ValidateLogFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, 2, lines, "validation must not close the shared file")
+
+	missing := filepath.Join(dir, "missing.log")
+	l = flash.New(flash.WithSinks(missing))
+	require.NoError(t, os.Remove(missing))
+	require.NoError(t, l.ValidateSinks())
+
+	_, err = os.Stat(missing)
+	assert.True(t, os.IsNotExist(err), "validation must not create files")
+
+	t.Run("tee and debug files", func(t *testing.T) {
+		tee := filepath.Join(dir, "missing", "tee.log")
+		debug := filepath.Join(dir, "missing", "debug.log")
+
+		l := flash.New(flash.WithSinks(os.DevNull), flash.WithConsoleAndFile(flash.FileConfig{Path: tee}),
+			flash.WithDebugFile(flash.FileConfig{Path: debug}))
+
+		err := l.ValidateSinks()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), tee)
+		assert.Contains(t, err.Error(), debug)
+		assert.NotContains(t, err.Error(), os.DevNull)
+	})
+}
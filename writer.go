@@ -0,0 +1,90 @@
+package flash
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// nolint: gochecknoglobals
+var levelPrefixes = map[string]zapcore.Level{
+	"DEBUG":   zapcore.DebugLevel,
+	"INFO":    zapcore.InfoLevel,
+	"WARN":    zapcore.WarnLevel,
+	"WARNING": zapcore.WarnLevel,
+	"ERROR":   zapcore.ErrorLevel,
+	"FATAL":   zapcore.ErrorLevel,
+	"PANIC":   zapcore.ErrorLevel,
+}
+
+// LevelParsingWriter returns a writer, which logs each written line at the level of its
+// leading level token like `WARN:` or `[ERROR]`, for example to log the output of a
+// subprocess. The token is removed from the message. Lines without a token are logged at
+// defaultLevel. Fatal and panic tokens are logged at `ErrorLevel`, so that the output of
+// a subprocess cannot stop the process. A line is logged, when its newline is written.
+func (l *Logger) LevelParsingWriter(defaultLevel zapcore.Level) io.Writer {
+	return &levelWriter{
+		l:            l,
+		defaultLevel: defaultLevel,
+	}
+}
+
+type levelWriter struct {
+	l            *Logger
+	defaultLevel zapcore.Level
+	m            sync.Mutex
+	buf          bytes.Buffer
+}
+
+// Write logs all complete lines of p and buffers the remainder.
+func (w *levelWriter) Write(p []byte) (int, error) {
+	w.m.Lock()
+	defer w.m.Unlock()
+
+	w.buf.Write(p)
+
+	for {
+		i := bytes.IndexByte(w.buf.Bytes(), '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+
+		line := string(w.buf.Next(i + 1))
+		w.log(strings.TrimRight(line, "\r\n"))
+	}
+}
+
+func (w *levelWriter) log(line string) {
+	level, msg := parseLevelPrefix(line, w.defaultLevel)
+
+	if ce := w.l.Desugar().WithOptions(zap.WithCaller(false)).Check(level, msg); ce != nil {
+		ce.Write()
+	}
+}
+
+// parseLevelPrefix returns the level of the leading level token of line and the line
+// without the token.
+func parseLevelPrefix(line string, defaultLevel zapcore.Level) (zapcore.Level, string) {
+	trimmed := strings.TrimLeft(line, " \t")
+
+	end := strings.IndexAny(trimmed, ": \t]")
+	if end < 0 {
+		end = len(trimmed)
+	}
+
+	token := strings.TrimPrefix(trimmed[:end], "[")
+
+	level, ok := levelPrefixes[strings.ToUpper(token)]
+	if !ok {
+		return defaultLevel, line
+	}
+
+	rest := strings.TrimPrefix(trimmed[end:], "]")
+	rest = strings.TrimPrefix(rest, ":")
+
+	return level, strings.TrimLeft(rest, " \t")
+}